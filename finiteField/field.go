@@ -0,0 +1,88 @@
+package finiteField
+
+import "math/bits"
+
+// Field is the arithmetic core for F_q on fixed-width integers.
+// Elements are uint32 values in [0, q). Reduction uses Barrett's method with
+// a 64-bit constant, so any uint64 can be reduced with one multiplication,
+// which lets dot products accumulate in a uint64 and reduce once at the end.
+// All operations run in constant time.
+// Precondition: 1 < q < 2^16
+type Field struct {
+	q uint32
+	// r is floor(2^64 / q)
+	r uint64
+}
+
+// NewField returns the arithmetic core for F_q
+func NewField(q int) Field {
+	return Field{uint32(q), ^uint64(0) / uint64(q)}
+}
+
+// Modulus returns q
+func (f Field) Modulus() int {
+	return int(f.q)
+}
+
+// csub subtracts q from x if x >= q without branching
+// Precondition: x < 2q
+func (f Field) csub(x uint32) uint32 {
+	x -= f.q
+	return x + (f.q & uint32(int32(x)>>31))
+}
+
+// Reduce returns x mod q
+func (f Field) Reduce(x uint64) uint32 {
+	hi, _ := bits.Mul64(x, f.r)
+	return f.csub(uint32(x - hi*uint64(f.q)))
+}
+
+// ReduceInt returns x mod q in [0, q) for any x, also a negative one
+func (f Field) ReduceInt(x int) uint32 {
+	// uint64(x) is x + 2^64 if x < 0, so 2^64 mod q is subtracted under a mask
+	mask := uint32(int64(x) >> 63)
+	two64 := f.Add(f.Reduce(^uint64(0)), 1)
+	return f.Sub(f.Reduce(uint64(x)), two64&mask)
+}
+
+// Add returns a + b mod q
+// Precondition: a, b < q
+func (f Field) Add(a, b uint32) uint32 {
+	return f.csub(a + b)
+}
+
+// Sub returns a - b mod q
+// Precondition: a, b < q
+func (f Field) Sub(a, b uint32) uint32 {
+	return f.csub(a + f.q - b)
+}
+
+// Neg returns -a mod q
+// Precondition: a < q
+func (f Field) Neg(a uint32) uint32 {
+	return f.csub(f.q - a)
+}
+
+// Mul returns a * b mod q
+// Precondition: a, b < q
+func (f Field) Mul(a, b uint32) uint32 {
+	return f.Reduce(uint64(a) * uint64(b))
+}
+
+// Exp returns a^e mod q. The sequence of operations only depends on e.
+func (f Field) Exp(a uint32, e int) uint32 {
+	r := uint32(1)
+	for i := bits.Len(uint(e)) - 1; i >= 0; i-- {
+		r = f.Mul(r, r)
+		// Multiply by a or by 1 depending on bit i of e
+		mask := uint32(-(e >> i & 1))
+		r = f.Mul(r, (a&mask)|(1&^mask))
+	}
+	return r
+}
+
+// Inv returns a^-1 mod q computed as a^(q-2), or 0 if a is 0
+// Precondition: q is prime
+func (f Field) Inv(a uint32) uint32 {
+	return f.Exp(a, int(f.q)-2)
+}
@@ -0,0 +1,102 @@
+package finiteField
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFieldReduce(t *testing.T) {
+	for _, q := range []int{4093, 2039} {
+		f := NewField(q)
+		for _, x := range []uint64{0, 1, uint64(q - 1), uint64(q), uint64(q) * uint64(q), math.MaxUint64, math.MaxUint64 - 1} {
+			if r := f.Reduce(x); uint64(r) != x%uint64(q) {
+				t.Errorf("q: %v x: %v r: %v", q, x, r)
+			}
+		}
+		for i := 0; i < 100000; i++ {
+			x := rand.Uint64() >> rand.Intn(64)
+			if r := f.Reduce(x); uint64(r) != x%uint64(q) {
+				t.Errorf("q: %v x: %v r: %v", q, x, r)
+			}
+		}
+	}
+}
+
+func TestFieldReduceInt(t *testing.T) {
+	for _, q := range []int{4093, 2039} {
+		f := NewField(q)
+		mod := func(x int) int {
+			r := x % q
+			if r < 0 {
+				r += q
+			}
+			return r
+		}
+		for _, x := range []int{0, 1, -1, q, -q, q - 1, 1 - q, math.MaxInt64, math.MinInt64, math.MinInt64 + 1} {
+			if r := f.ReduceInt(x); int(r) != mod(x) {
+				t.Errorf("q: %v x: %v r: %v", q, x, r)
+			}
+		}
+		for i := 0; i < 100000; i++ {
+			x := int(rand.Uint64()) >> rand.Intn(64)
+			if r := f.ReduceInt(x); int(r) != mod(x) {
+				t.Errorf("q: %v x: %v r: %v", q, x, r)
+			}
+		}
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	for _, q := range []int{4093, 2039} {
+		f := NewField(q)
+		for i := 0; i < 10000; i++ {
+			a := rand.Intn(q)
+			b := rand.Intn(q)
+			x := NewFieldElm(a, q)
+			y := NewFieldElm(b, q)
+			if int(f.Add(uint32(a), uint32(b))) != x.Add(y).Value() {
+				t.Errorf("q: %v add %v %v", q, a, b)
+			}
+			if int(f.Sub(uint32(a), uint32(b))) != x.Sub(y).Value() {
+				t.Errorf("q: %v sub %v %v", q, a, b)
+			}
+			if int(f.Mul(uint32(a), uint32(b))) != x.Mul(y).Value() {
+				t.Errorf("q: %v mul %v %v", q, a, b)
+			}
+			if int(f.Neg(uint32(a))) != x.UnaryMinus().Value() {
+				t.Errorf("q: %v neg %v", q, a)
+			}
+		}
+	}
+}
+
+func TestFieldInv(t *testing.T) {
+	for _, q := range []int{4093, 2039} {
+		f := NewField(q)
+		for a := 1; a < q; a++ {
+			if f.Mul(uint32(a), f.Inv(uint32(a))) != 1 {
+				t.Errorf("q: %v a: %v inv: %v", q, a, f.Inv(uint32(a)))
+			}
+		}
+		if f.Inv(0) != 0 {
+			t.Errorf("q: %v inv(0): %v", q, f.Inv(0))
+		}
+	}
+}
+
+func BenchmarkFieldMul(b *testing.B) {
+	f := NewField(q)
+	x := uint32(3351)
+	for i := 0; i < b.N; i++ {
+		x = f.Mul(x, 1234)
+	}
+}
+
+func BenchmarkFqMul(b *testing.B) {
+	x := NewFieldElm(3351, q)
+	y := NewFieldElm(1234, q)
+	for i := 0; i < b.N; i++ {
+		x = x.Mul(y)
+	}
+}
@@ -10,12 +10,10 @@ type Fq struct {
 	q int
 }
 
+// mod returns n mod q in [0, q). It does not branch on n, since Fq holds
+// secret values during key generation.
 func mod(n int, q int) int {
-	r := n % q
-	if r < 0 {
-		r += q
-	}
-	return r
+	return int(NewField(q).ReduceInt(n))
 }
 
 func NewFieldElm(n int, q int) *Fq {
@@ -46,6 +44,8 @@ func (x *Fq) Mul(y *Fq) *Fq {
 	return NewFieldElm(n, x.q)
 }
 
+// Inverse returns x with a x = 1 mod b by the extended Euclidean algorithm.
+// The running time depends on a and b, so Fq.Inv and Field.Inv are used for secret values.
 func Inverse(a, b int) int {
 	x := 1
 	y := 0
@@ -62,8 +62,10 @@ func Inverse(a, b int) int {
 	return x
 }
 
+// Inv returns x^-1 computed in constant time as x^(q-2)
+// Precondition: q is prime
 func (x *Fq) Inv() *Fq {
-	return NewFieldElm(Inverse(int(x.n), int(x.q)), x.q)
+	return NewFieldElm(int(NewField(x.q).Inv(uint32(x.n))), x.q)
 }
 
 func (x *Fq) UnaryMinus() *Fq {
@@ -2,6 +2,8 @@ module meds
 
 go 1.22.2
 
-require golang.org/x/crypto v0.24.0
-
-require golang.org/x/sys v0.21.0 // indirect
+require (
+	github.com/BurntSushi/toml v1.4.0
+	golang.org/x/crypto v0.24.0
+	golang.org/x/sys v0.21.0
+)
@@ -0,0 +1,9 @@
+//go:build race
+
+// Package racetest reports whether the race detector is on, for tests that
+// count allocations. The race detector makes sync.Pool drop items at random,
+// so allocation counts are not meaningful under it.
+package racetest
+
+// Enabled is set when the race detector is on
+const Enabled = true
@@ -0,0 +1,76 @@
+package kat
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+// DRBG is the AES-256 CTR_DRBG without derivation function used by the NIST
+// PQC known-answer test generators (randombytes_init and randombytes in rng.c)
+type DRBG struct {
+	block          cipher.Block
+	key            [32]byte
+	v              [16]byte
+	reseed_counter int
+}
+
+// NewDRBG initializes a DRBG from 48 bytes of entropy and an optional 48 byte
+// personalization string, as randombytes_init does
+func NewDRBG(entropy, personalization []byte) (*DRBG, error) {
+	if len(entropy) != 48 {
+		return nil, errors.New("kat: entropy input must be 48 bytes")
+	}
+	if personalization != nil && len(personalization) != 48 {
+		return nil, errors.New("kat: personalization string must be 48 bytes")
+	}
+	seed_material := make([]byte, 48)
+	copy(seed_material, entropy)
+	for i := 0; i < len(personalization); i++ {
+		seed_material[i] ^= personalization[i]
+	}
+	d := &DRBG{}
+	d.update(seed_material)
+	d.reseed_counter = 1
+	return d, nil
+}
+
+// Read fills b with pseudorandom bytes, as one call of randombytes does.
+// It never fails.
+func (d *DRBG) Read(b []byte) (int, error) {
+	block := make([]byte, 16)
+	for i := 0; i < len(b); i += 16 {
+		d.incrementV()
+		d.block.Encrypt(block, d.v[:])
+		copy(b[i:], block)
+	}
+	d.update(nil)
+	d.reseed_counter++
+	return len(b), nil
+}
+
+func (d *DRBG) incrementV() {
+	for j := 15; j >= 0; j-- {
+		d.v[j]++
+		if d.v[j] != 0 {
+			break
+		}
+	}
+}
+
+// update is AES256_CTR_DRBG_Update. It derives a new key and V from the
+// current state and the optional 48 bytes of provided_data.
+func (d *DRBG) update(provided_data []byte) {
+	block, _ := aes.NewCipher(d.key[:])
+	temp := make([]byte, 48)
+	for i := 0; i < 3; i++ {
+		d.incrementV()
+		block.Encrypt(temp[16*i:], d.v[:])
+	}
+	for i := 0; i < len(provided_data); i++ {
+		temp[i] ^= provided_data[i]
+	}
+	copy(d.key[:], temp[:32])
+	copy(d.v[:], temp[32:])
+	d.block, _ = aes.NewCipher(d.key[:])
+}
@@ -0,0 +1,160 @@
+package kat
+
+import (
+	"bytes"
+	"encoding/hex"
+	"meds/meds"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDRBG(test *testing.T) {
+	entropy := make([]byte, 48)
+	for i := 0; i < len(entropy); i++ {
+		entropy[i] = byte(i)
+	}
+	drbg, err := NewDRBG(entropy, nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	// First seed and message of every PQCsignKAT_*.rsp file generated by the NIST tooling
+	E_seed, _ := hex.DecodeString("061550234D158C5EC95595FE04EF7A25767F2E24CC2BC479D09D86DC9ABCFDE7056A8C266F9EF97ED08541DBD2E1FFA1")
+	E_msg, _ := hex.DecodeString("D81C4D8D734FCBFBEADE3D3F8A039FAA2A2C9957E835AD55B22E75BF57BB556AC8")
+	seed := make([]byte, 48)
+	msg := make([]byte, 33)
+	drbg.Read(seed)
+	drbg.Read(msg)
+	if !bytes.Equal(seed, E_seed) {
+		test.Errorf("seed: %X\n", seed)
+	}
+	if !bytes.Equal(msg, E_msg) {
+		test.Errorf("msg: %X\n", msg)
+	}
+}
+
+func TestRsp(test *testing.T) {
+	vectors := []Vector{
+		{0, []byte{1, 2}, []byte{3}, []byte{4, 5}, []byte{6}, []byte{7, 8, 9}},
+		{1, []byte{10}, []byte{}, []byte{11}, []byte{12}, []byte{13}},
+	}
+	var b bytes.Buffer
+	err := WriteRsp(&b, "MEDS1", vectors)
+	if err != nil {
+		test.Fatal(err)
+	}
+	name, R, err := ReadRsp(&b)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if name != "MEDS1" || len(R) != len(vectors) {
+		test.Fatalf("name: %v, vectors: %v\n", name, R)
+	}
+	for i := range vectors {
+		if R[i].Count != vectors[i].Count || !bytes.Equal(R[i].Seed, vectors[i].Seed) || !bytes.Equal(R[i].Msg, vectors[i].Msg) ||
+			!bytes.Equal(R[i].PK, vectors[i].PK) || !bytes.Equal(R[i].SK, vectors[i].SK) || !bytes.Equal(R[i].SM, vectors[i].SM) {
+			test.Errorf("\nR: %v\nE: %v\n", R[i], vectors[i])
+		}
+	}
+}
+
+// TestKAT checks the reference KATs in testdata/reference byte for byte,
+// see testdata/reference/README.md. It is skipped while none have been added.
+func TestKAT(test *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "reference", "*.rsp"))
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(files) == 0 {
+		test.Skip("no reference KATs in testdata/reference")
+	}
+	checked := map[string]bool{}
+	for _, file := range files {
+		name, vectors := readRspFile(test, file)
+		if vectors == nil {
+			continue
+		}
+		scheme := schemeForName(name)
+		if scheme == nil {
+			test.Errorf("%v: unknown parameter set %v\n", file, name)
+			continue
+		}
+		checked[name] = true
+		if len(vectors) == 0 || vectors[0].Count != 0 {
+			test.Errorf("%v: count 0 is missing\n", file)
+		}
+		for _, v := range vectors {
+			err = Check(scheme, v)
+			if err != nil {
+				test.Errorf("%v: %v\n", name, err)
+			}
+		}
+	}
+	for _, set := range meds.ParameterSets() {
+		scheme, err := meds.NewScheme(set)
+		if err != nil {
+			test.Fatal(err)
+		}
+		// The toy set 1 is not part of the NIST submission
+		if set != 1 && !checked[Name(scheme)] {
+			test.Errorf("no reference KAT for %v\n", Name(scheme))
+		}
+	}
+}
+
+// TestSnapshot checks the vectors in testdata/snapshot. They are regression
+// snapshots generated by this implementation with
+// go run . -meds <set> -op KAT -count <n>, see testdata/snapshot/README.md.
+func TestSnapshot(test *testing.T) {
+	for _, set := range meds.ParameterSets() {
+		scheme, err := meds.NewScheme(set)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if set != 1 && testing.Short() {
+			continue
+		}
+		file := filepath.Join("testdata", "snapshot", FileName(scheme))
+		name, vectors := readRspFile(test, file)
+		if vectors == nil {
+			continue
+		}
+		if name != Name(scheme) {
+			test.Errorf("%v: header is %v, expected %v\n", file, name, Name(scheme))
+		}
+		for _, v := range vectors {
+			err = Check(scheme, v)
+			if err != nil {
+				test.Errorf("%v: %v\n", Name(scheme), err)
+			}
+		}
+	}
+}
+
+// readRspFile reads the .rsp file at path
+// Returns: (name, vectors), or nil vectors after reporting an error
+func readRspFile(test *testing.T, path string) (string, []Vector) {
+	f, err := os.Open(path)
+	if err != nil {
+		test.Errorf("%v\n", err)
+		return "", nil
+	}
+	defer f.Close()
+	name, vectors, err := ReadRsp(f)
+	if err != nil {
+		test.Errorf("%v: %v\n", path, err)
+		return "", nil
+	}
+	return name, vectors
+}
+
+// schemeForName returns the built-in parameter set whose Name is name, or nil
+func schemeForName(name string) *meds.Scheme {
+	for _, set := range meds.ParameterSets() {
+		scheme, err := meds.NewScheme(set)
+		if err == nil && Name(scheme) == name {
+			return scheme
+		}
+	}
+	return nil
+}
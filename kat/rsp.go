@@ -0,0 +1,127 @@
+package kat
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Vector is one entry of a PQCsignKAT_*.rsp file
+type Vector struct {
+	Count int
+	Seed  []byte
+	Msg   []byte
+	PK    []byte
+	SK    []byte
+	SM    []byte
+}
+
+// WriteRsp writes the vectors in the NIST .rsp format with name as the header comment
+func WriteRsp(w io.Writer, name string, vectors []Vector) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %v\n\n", name)
+	for _, v := range vectors {
+		fmt.Fprintf(b, "count = %v\n", v.Count)
+		fmt.Fprintf(b, "seed = %X\n", v.Seed)
+		fmt.Fprintf(b, "mlen = %v\n", len(v.Msg))
+		fmt.Fprintf(b, "msg = %X\n", v.Msg)
+		fmt.Fprintf(b, "pk = %X\n", v.PK)
+		fmt.Fprintf(b, "sk = %X\n", v.SK)
+		fmt.Fprintf(b, "smlen = %v\n", len(v.SM))
+		fmt.Fprintf(b, "sm = %X\n\n", v.SM)
+	}
+	return b.Flush()
+}
+
+// ReadRsp parses a file in the NIST .rsp format
+// Returns: (name, vectors, error) where name is the header comment
+func ReadRsp(r io.Reader) (string, []Vector, error) {
+	name := ""
+	vectors := []Vector{}
+	var v *Vector
+	mlen, smlen := 0, 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<20), 1<<24)
+	line_nr := 0
+	for scanner.Scan() {
+		line_nr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if name == "" {
+				name = strings.TrimSpace(line[1:])
+			}
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return "", nil, fmt.Errorf("kat: line %v: expected key = value", line_nr)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "count" {
+			if v != nil {
+				if err := checkLengths(v, mlen, smlen); err != nil {
+					return "", nil, err
+				}
+				vectors = append(vectors, *v)
+			}
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("kat: line %v: %v", line_nr, err)
+			}
+			v = &Vector{Count: count}
+			continue
+		}
+		if v == nil {
+			return "", nil, fmt.Errorf("kat: line %v: %v before count", line_nr, key)
+		}
+		var err error
+		switch key {
+		case "seed":
+			v.Seed, err = hex.DecodeString(value)
+		case "msg":
+			v.Msg, err = hex.DecodeString(value)
+		case "pk":
+			v.PK, err = hex.DecodeString(value)
+		case "sk":
+			v.SK, err = hex.DecodeString(value)
+		case "sm":
+			v.SM, err = hex.DecodeString(value)
+		case "mlen":
+			mlen, err = strconv.Atoi(value)
+		case "smlen":
+			smlen, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown key %v", key)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("kat: line %v: %v", line_nr, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	if v != nil {
+		if err := checkLengths(v, mlen, smlen); err != nil {
+			return "", nil, err
+		}
+		vectors = append(vectors, *v)
+	}
+	return name, vectors, nil
+}
+
+func checkLengths(v *Vector, mlen, smlen int) error {
+	if len(v.Msg) != mlen {
+		return fmt.Errorf("kat: count %v: mlen is %v but msg is %v bytes", v.Count, mlen, len(v.Msg))
+	}
+	if len(v.SM) != smlen {
+		return fmt.Errorf("kat: count %v: smlen is %v but sm is %v bytes", v.Count, smlen, len(v.SM))
+	}
+	return nil
+}
@@ -3,42 +3,52 @@ package main
 import (
 	"flag"
 	"fmt"
+	"meds/kat"
 	"meds/meds"
+	"meds/security"
 	"os"
+	"strconv"
 )
 
 func main() {
-	parameterSet := flag.Int("meds", 9923, "MEDS parameter set (9923, 13220, 41711, 69497, 134180, 167717)")
-	op := flag.String("op", "", "The MEDS operation to do (Keygen, Sign, Verify)")
+	parameterSet := flag.String("meds", "9923", "MEDS parameter set (1, 9923, 13220, 41711, 69497, 134180, 167717), or the path to a .json or .toml parameter file")
+	op := flag.String("op", "", "The MEDS operation to do (Keygen, Sign, Verify, KAT, Security, Search)")
 	msg_file := flag.String("msg", "example.txt", "The message to sign")
 	signed_file := flag.String("signed", "example.txt.signed", "Path to the message to verify")
+	kat_count := flag.Int("count", 100, "Number of known-answer test vectors to generate")
+	compact := flag.Bool("compact", false, "Save only the secret seed in the private key file")
+	lambda := flag.Float64("lambda", 128, "Target soundness in bits for Search")
+	max_s := flag.Int("max-s", 16, "Largest s tried by Search")
+	max_t := flag.Int("max-t", 2048, "Largest t tried by Search")
+	as_json := flag.Bool("json", false, "Write the Search results as JSON")
 
 	flag.Parse()
 
-	switch *parameterSet {
-	case 9923:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	case 13220:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	case 41711:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	case 69497:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	case 134180:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	case 167717:
-		fmt.Printf("Chosen Parameterset: %v\n", *parameterSet)
-	default:
-		fmt.Printf("Invalid parameter set\n")
+	scheme, err := loadScheme(*parameterSet)
+	if err != nil {
+		fmt.Printf("Invalid parameter set. %v\n", err)
 		flag.Usage()
 		return
 	}
-	meds.ParameterSetup(*parameterSet)
+	if !*as_json {
+		fmt.Printf("Chosen Parameterset: %v\n", scheme.ParameterSet())
+	}
 	switch *op {
 	case "Keygen":
-		pk, sk := meds.KeyGen()
+		pk, sk, err := scheme.KeyGen(nil)
+		if err != nil {
+			fmt.Printf("Error generating keys. %v\n", err)
+			return
+		}
+		if *compact {
+			sk, err = scheme.CompactPrivateKey(sk)
+			if err != nil {
+				fmt.Printf("Error compacting private key. %v\n", err)
+				return
+			}
+		}
 
-		err := os.WriteFile("meds_key", sk, 0666)
+		err = os.WriteFile("meds_key", sk, 0666)
 		if err != nil {
 			fmt.Printf("Error writing to private key file\n")
 			return
@@ -60,7 +70,7 @@ func main() {
 			fmt.Printf("Error reading message file\n")
 			return
 		}
-		signed_msg, err := meds.Sign(sk, msg)
+		signed_msg, err := scheme.Sign(nil, sk, msg)
 		if err != nil {
 			fmt.Printf("Error signing message. %v\n", err)
 			return
@@ -82,14 +92,76 @@ func main() {
 			fmt.Printf("Error reading signed file\n")
 			return
 		}
-		msg := meds.Verify(pk, msg_signed)
-		if msg == nil {
-			fmt.Printf("Invalid Signature\n")
+		_, err = scheme.Verify(pk, msg_signed)
+		if err != nil {
+			fmt.Printf("Invalid Signature. %v\n", err)
 			return
 		}
 		fmt.Printf("Valid Signature\n")
+	case "Security":
+		// Without -meds, all built-in parameter sets are compared
+		reports := security.EstimateBuiltin()
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "meds" {
+				reports = []security.Report{security.Estimate(scheme.Parameters())}
+			}
+		})
+		err := security.WriteTable(os.Stdout, reports)
+		if err != nil {
+			fmt.Printf("Error writing security estimates. %v\n", err)
+			return
+		}
+	case "Search":
+		// q, m, n, k and the seed lengths are those of the chosen parameter set
+		params := scheme.Parameters()
+		candidates, err := security.Search(params, *lambda, *max_s, *max_t)
+		if err != nil {
+			fmt.Printf("Error searching parameters. %v\n", err)
+			return
+		}
+		if attacks := security.Estimate(params); min(attacks.Leon.Classical, attacks.Birthday.Classical, attacks.Algebraic.Classical) < *lambda {
+			fmt.Fprintf(os.Stderr, "Warning: attacks on the code are estimated below %v bits\n", *lambda)
+		}
+		front := security.ParetoFront(candidates)
+		if *as_json {
+			err = security.WriteCandidatesJSON(os.Stdout, front)
+		} else {
+			err = security.WriteCandidates(os.Stdout, front)
+		}
+		if err != nil {
+			fmt.Printf("Error writing search results. %v\n", err)
+			return
+		}
+	case "KAT":
+		vectors, err := kat.Generate(scheme, *kat_count)
+		if err != nil {
+			fmt.Printf("Error generating known-answer tests. %v\n", err)
+			return
+		}
+		f, err := os.Create(kat.FileName(scheme))
+		if err != nil {
+			fmt.Printf("Error creating known-answer test file. %v\n", err)
+			return
+		}
+		defer f.Close()
+		err = kat.WriteRsp(f, kat.Name(scheme), vectors)
+		if err != nil {
+			fmt.Printf("Error writing known-answer test file. %v\n", err)
+			return
+		}
+		fmt.Printf("Known-answer tests saved to %v\n", kat.FileName(scheme))
 	default:
 		fmt.Printf("Invalid Operation\n")
 		flag.Usage()
 	}
 }
+
+// loadScheme returns the built-in parameter set named by set, or the
+// parameter set defined in the file at path set
+func loadScheme(set string) (*meds.Scheme, error) {
+	n, err := strconv.Atoi(set)
+	if err == nil {
+		return meds.NewScheme(n)
+	}
+	return meds.LoadParameters(set)
+}
@@ -0,0 +1,64 @@
+package matrix
+
+import "math/bits"
+
+// BitLen returns the number of bits of an element of F_q, ceil(log2 q)
+// Precondition: q > 1
+func BitLen(q int) int {
+	return bits.Len(uint(q - 1))
+}
+
+// bitWriter packs values of a fixed bit width into bytes, least significant
+// bit first, as the bitstream of the MEDS specification.
+type bitWriter struct {
+	b   []byte
+	acc uint64
+	// n is the number of bits in acc
+	n uint
+}
+
+// write appends the low width bits of v
+// Precondition: width <= 32
+func (w *bitWriter) write(v uint32, width int) {
+	w.acc |= uint64(v&(1<<width-1)) << w.n
+	w.n += uint(width)
+	for w.n >= 8 {
+		w.b = append(w.b, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+// flush appends the remaining bits, padded with zeroes to a whole byte
+// Returns: The packed bytes
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.b = append(w.b, byte(w.acc))
+		w.acc, w.n = 0, 0
+	}
+	return w.b
+}
+
+// bitReader unpacks the bitstream written by bitWriter
+type bitReader struct {
+	b   []byte
+	acc uint64
+	// n is the number of bits in acc
+	n uint
+}
+
+// read returns the next width bits. Bits past the end of b read as zero.
+// Precondition: width <= 32
+func (r *bitReader) read(width int) uint32 {
+	for r.n < uint(width) {
+		if len(r.b) > 0 {
+			r.acc |= uint64(r.b[0]) << r.n
+			r.b = r.b[1:]
+		}
+		r.n += 8
+	}
+	v := uint32(r.acc & (1<<width - 1))
+	r.acc >>= width
+	r.n -= uint(width)
+	return v
+}
@@ -0,0 +1,62 @@
+package matrix
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBitLen(t *testing.T) {
+	for _, c := range []struct{ q, bits int }{{2, 1}, {3, 2}, {4, 2}, {5, 3}, {2039, 11}, {2048, 11}, {2049, 12}, {4093, 12}, {65521, 16}} {
+		if BitLen(c.q) != c.bits {
+			t.Errorf("BitLen(%v) = %v, expected %v", c.q, BitLen(c.q), c.bits)
+		}
+	}
+}
+
+// TestCompressRoundTrip checks Decompress(Compress(A)) == A for random
+// matrices over every supported q, and that the padding bits are zero
+func TestCompressRoundTrip(t *testing.T) {
+	for _, q := range []int{2039, 4093, 2, 3, 251, 8191, 65521} {
+		for trial := 0; trial < 100; trial++ {
+			A := New(1+rand.Intn(20), 1+rand.Intn(20), q)
+			for i := range A.matrix {
+				A.matrix[i] = uint16(rand.Intn(q))
+			}
+			// Elements next to 0 and q-1 exercise every bit
+			A.matrix[0] = uint16(q - 1)
+			b := A.Compress()
+			if len(b) != CompressedSize(A.M, A.N, q) || len(b) != (A.M*A.N*BitLen(q)+7)/8 {
+				t.Fatalf("q = %v: %v x %v matrix compressed to %v bytes", q, A.M, A.N, len(b))
+			}
+			if padding := A.M * A.N * BitLen(q) % 8; padding != 0 && b[len(b)-1]>>padding != 0 {
+				t.Errorf("q = %v: padding bits are not zero: %08b", q, b[len(b)-1])
+			}
+			R := Decompress(b, A.M, A.N, q)
+			if !R.Equals(A) {
+				t.Fatalf("q = %v\nCompressed:   %v\nDecompressed: %v\nA:            %v", q, b, R.matrix, A.matrix)
+			}
+		}
+	}
+}
+
+func TestBitWriter(t *testing.T) {
+	// 0x5 in 3 bits, 0x1FF in 9 bits and 0x2 in 2 bits, least significant bit first
+	var w bitWriter
+	w.write(0x5, 3)
+	w.write(0x1FF, 9)
+	w.write(0x2, 2)
+	b := w.flush()
+	if len(b) != 2 || b[0] != 0xFD || b[1] != 0x2F {
+		t.Fatalf("Packed: %X", b)
+	}
+	r := bitReader{b: b}
+	if v := r.read(3); v != 0x5 {
+		t.Errorf("Read %X, expected 5", v)
+	}
+	if v := r.read(9); v != 0x1FF {
+		t.Errorf("Read %X, expected 1FF", v)
+	}
+	if v := r.read(2); v != 0x2 {
+		t.Errorf("Read %X, expected 2", v)
+	}
+}
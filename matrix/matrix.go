@@ -1,39 +1,75 @@
 package matrix
 
 import (
-	"math"
 	"meds/finiteField"
+	"slices"
 	"strings"
+	"sync"
 )
 
+// Matrix is an M by N matrix over F_Q. The entries are stored in one
+// contiguous slice in row-major order, each reduced to [0, Q).
 type Matrix struct {
 	M      int
 	N      int
 	Q      int
-	matrix [][]*finiteField.Fq
+	matrix []uint16
 }
 
-// Get returns the element at position (i, j) in the matrix
-// Precondition: i > 0 && j > 0 && i < A.M && j < A.N
+// Get returns the element at position (i, j) in the matrix.
+// The element is a copy, so use Set to change the matrix.
+// Precondition: i >= 0 && j >= 0 && i < A.M && j < A.N
 // Returns $a_{ij}$
 func (A *Matrix) Get(i, j int) *finiteField.Fq {
-	return A.matrix[i][j]
+	return finiteField.NewFieldElm(int(A.matrix[i*A.N+j]), A.Q)
 }
 
+// Set sets the element at position (i, j) in the matrix to elm
 func (A *Matrix) Set(i, j int, elm *finiteField.Fq) {
-	A.matrix[i][j] = elm
+	A.matrix[i*A.N+j] = uint16(elm.Value())
+}
+
+// GetValue returns the element at position (i, j) as an integer in [0, A.Q)
+func (A *Matrix) GetValue(i, j int) int {
+	return int(A.matrix[i*A.N+j])
+}
+
+// SetValue sets the element at position (i, j) to v
+// Precondition: 0 <= v < A.Q
+func (A *Matrix) SetValue(i, j, v int) {
+	A.matrix[i*A.N+j] = uint16(v)
+}
+
+// Row returns row i of the matrix. The slice shares memory with the matrix.
+func (A *Matrix) Row(i int) []uint16 {
+	return A.matrix[i*A.N : (i+1)*A.N]
+}
+
+// Data returns the entries of the matrix in row-major order.
+// The slice shares memory with the matrix.
+func (A *Matrix) Data() []uint16 {
+	return A.matrix
+}
+
+// Copy returns a copy of the matrix
+func (A *Matrix) Copy() *Matrix {
+	R := New(A.M, A.N, A.Q)
+	copy(R.matrix, A.matrix)
+	return R
 }
 
 func (A *Matrix) Submatrix(startRow, endRow, startCol, endCol int) *Matrix {
-	M := New(endRow-startRow, endCol-startCol, A.Q)
+	return A.SubmatrixInto(New(endRow-startRow, endCol-startCol, A.Q), startRow, startCol)
+}
 
-	for i := 0; i < M.M; i++ {
-		for j := 0; j < M.N; j++ {
-			M.Set(i, j, A.Get(i+startRow, j+startCol))
-		}
+// SubmatrixInto copies the R.M x R.N submatrix of A starting at (startRow, startCol) into R
+// Returns: R
+func (A *Matrix) SubmatrixInto(R *Matrix, startRow, startCol int) *Matrix {
+	for i := 0; i < R.M; i++ {
+		copy(R.Row(i), A.Row(i + startRow)[startCol:startCol+R.N])
 	}
 
-	return M
+	return R
 }
 
 func (A *Matrix) String() string {
@@ -57,35 +93,32 @@ func (A *Matrix) String() string {
 // Precondition: m > 0 and n > 0
 // Returns: $M_{mn}$ initialized to all zeroes
 func New(m int, n int, q int) *Matrix {
-	matrix := make([][]*finiteField.Fq, m)
-
-	for i := range matrix {
-		matrix[i] = make([]*finiteField.Fq, n)
-	}
+	return &Matrix{m, n, q, make([]uint16, m*n)}
+}
 
-	for i := 0; i < m; i++ {
-		for j := 0; j < n; j++ {
-			matrix[i][j] = finiteField.NewFieldElm(0, q)
-		}
+// NewBatch initializes count m by n matrices that share one allocation
+// Precondition: count > 0, m > 0 and n > 0
+// Returns: count matrices $M_{mn}$ initialized to all zeroes
+func NewBatch(count, m, n, q int) []*Matrix {
+	data := make([]uint16, count*m*n)
+	matrices := make([]Matrix, count)
+	batch := make([]*Matrix, count)
+	for i := range batch {
+		matrices[i] = Matrix{m, n, q, data[i*m*n : (i+1)*m*n : (i+1)*m*n]}
+		batch[i] = &matrices[i]
 	}
 
-	return &Matrix{m, n, q, matrix}
+	return batch
 }
 
 func New_with_default(m, n, q, val int) *Matrix {
-	matrix := make([][]*finiteField.Fq, m)
-
-	for i := range matrix {
-		matrix[i] = make([]*finiteField.Fq, n)
-	}
-
-	for i := 0; i < m; i++ {
-		for j := 0; j < n; j++ {
-			matrix[i][j] = finiteField.NewFieldElm(val, q)
-		}
+	M := New(m, n, q)
+	v := uint16(finiteField.NewFieldElm(val, q).Value())
+	for i := range M.matrix {
+		M.matrix[i] = v
 	}
 
-	return &Matrix{m, n, q, matrix}
+	return M
 }
 
 // Identity creates an identity matrix of the specified size
@@ -94,7 +127,7 @@ func Identity(n int, q int) *Matrix {
 	I := New(n, n, q)
 
 	for i := 0; i < n; i++ {
-		I.Get(i, i).Set(1)
+		I.SetValue(i, i, 1)
 	}
 
 	return I
@@ -105,7 +138,7 @@ func UpperShift(d int, q int) *Matrix {
 	U := New(d, d, q)
 
 	for i := 1; i < d; i++ {
-		U.Get(i-1, i).Set(1)
+		U.SetValue(i-1, i, 1)
 	}
 
 	return U
@@ -115,27 +148,41 @@ func UpperShift(d int, q int) *Matrix {
 // Precondition: Matricies are of the same dimentions
 // Returns: $A = B$
 func (A *Matrix) Equals(B *Matrix) bool {
-	equal := B != nil
+	equal := B != nil && A.Q == B.Q
 
 	for i := 0; equal && i < A.M; i++ {
 		for j := 0; equal && j < A.N; j++ {
-			equal = A.Get(i, j).Equals(B.Get(i, j))
+			equal = A.matrix[i*A.N+j] == B.matrix[i*B.N+j]
 		}
 	}
 
 	return equal
 }
 
+// ConstantTimeEquals is Equals without an early exit, so its running time
+// only depends on the dimensions and not on the entries
+// Returns: $A = B$
+func (A *Matrix) ConstantTimeEquals(B *Matrix) bool {
+	if B == nil || A.Q != B.Q || len(A.matrix) != len(B.matrix) {
+		return false
+	}
+	diff := uint16(0)
+	for i, a := range A.matrix {
+		diff |= a ^ B.matrix[i]
+	}
+
+	return diff == 0
+}
+
 // Add is the addition operation on matricies.
 // Precondition: Matricies are of the same dimentions
 // Returns: $A + B$
 func (A *Matrix) Add(B *Matrix) *Matrix {
 	R := New(A.M, A.N, A.Q)
+	f := finiteField.NewField(A.Q)
 
-	for i := 0; i < A.M; i++ {
-		for j := 0; j < A.N; j++ {
-			R.Set(i, j, A.Get(i, j).Add(B.Get(i, j)))
-		}
+	for i := range R.matrix {
+		R.matrix[i] = uint16(f.Add(uint32(A.matrix[i]), uint32(B.matrix[i])))
 	}
 
 	return R
@@ -146,11 +193,10 @@ func (A *Matrix) Add(B *Matrix) *Matrix {
 // Returns: $A - B$
 func (A *Matrix) Sub(B *Matrix) *Matrix {
 	R := New(A.M, A.N, A.Q)
+	f := finiteField.NewField(A.Q)
 
-	for i := 0; i < A.M; i++ {
-		for j := 0; j < A.N; j++ {
-			R.Set(i, j, A.Get(i, j).Sub(B.Get(i, j)))
-		}
+	for i := range R.matrix {
+		R.matrix[i] = uint16(f.Sub(uint32(A.matrix[i]), uint32(B.matrix[i])))
 	}
 
 	return R
@@ -160,33 +206,81 @@ func (A *Matrix) Sub(B *Matrix) *Matrix {
 // Returns: c A
 func (A *Matrix) Scalar_mul(c *finiteField.Fq) *Matrix {
 	R := New(A.M, A.N, A.Q)
+	f := finiteField.NewField(A.Q)
+	c_v := uint32(c.Value())
 
-	for i := 0; i < A.M; i++ {
-		for j := 0; j < A.N; j++ {
-			R.Set(i, j, A.Get(i, j).Mul(c))
-		}
+	for i := range R.matrix {
+		R.matrix[i] = uint16(f.Mul(uint32(A.matrix[i]), c_v))
 	}
 
 	return R
 }
 
-// Mul is the multiplication operation on matricies
+// Mul is the multiplication operation on matricies.
+// On amd64 CPUs with AVX2 the product is computed by an assembly kernel.
 // Precondition: A.N == B.M
 // Returns: $A \cdot B$
 func (A *Matrix) Mul(B *Matrix) *Matrix {
-	R := New(A.M, B.N, A.Q)
+	return A.MulInto(B, New(A.M, B.N, A.Q))
+}
+
+// MulInto computes A B into R without allocating R
+// Precondition: A.N == B.M, R is A.M x B.N and does not share memory with A or B
+// Returns: R
+func (A *Matrix) MulInto(B, R *Matrix) *Matrix {
+	mul(R, A, B)
+	return R
+}
+
+// mulScratch holds the accumulators of one matrix product. They are pooled
+// so that repeated products do not allocate.
+type mulScratch struct {
+	acc64 []uint64
+	acc   []uint32
+	a     []uint32
+}
+
+var mulScratchPool = sync.Pool{New: func() any { return new(mulScratch) }}
+
+// putMulScratch wipes the accumulators, which may hold products of secret
+// matrices, and returns them to the pool
+func putMulScratch(scratch *mulScratch) {
+	clear(scratch.acc64)
+	clear(scratch.acc)
+	clear(scratch.a)
+	mulScratchPool.Put(scratch)
+}
+
+// grow returns s resized to n elements, reusing its memory if possible
+func grow[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
+
+// mulGeneric computes R = A B in pure Go. Row i of R is accumulated in uint64
+// as the sum of a_ik times row k of B and reduced once at the end.
+func mulGeneric(R, A, B *Matrix) {
+	f := finiteField.NewField(A.Q)
+	scratch := mulScratchPool.Get().(*mulScratch)
+	defer putMulScratch(scratch)
+	scratch.acc64 = grow(scratch.acc64, B.N)
+	acc := scratch.acc64
 
 	for i := 0; i < R.M; i++ {
-		for j := 0; j < R.N; j++ {
-			elm := finiteField.NewFieldElm(0, R.Q)
-			for k := 0; k < A.N; k++ {
-				elm = elm.Add(A.Get(i, k).Mul(B.Get(k, j)))
+		clear(acc)
+		for k, a := range A.Row(i) {
+			a_ik := uint64(a)
+			for j, b := range B.Row(k) {
+				acc[j] += a_ik * uint64(b)
 			}
-			R.Set(i, j, elm)
+		}
+		R_i := R.Row(i)
+		for j, v := range acc {
+			R_i[j] = uint16(f.Reduce(v))
 		}
 	}
-
-	return R
 }
 
 // Transpose is the transpose operation on a Matrix
@@ -196,7 +290,7 @@ func (A *Matrix) Transpose() *Matrix {
 
 	for i := 0; i < A.N; i++ {
 		for j := 0; j < A.M; j++ {
-			R.Set(i, j, A.Get(j, i))
+			R.matrix[i*R.N+j] = A.matrix[j*A.N+i]
 		}
 	}
 
@@ -207,24 +301,15 @@ func (A *Matrix) Transpose() *Matrix {
 // Returns: $A \otimes B$
 func (A *Matrix) Kroenecker_product(B *Matrix) *Matrix {
 	R := New(A.M*B.M, A.N*B.N, A.Q)
-
-	all_a_ij_times_B := make([]Matrix, A.M*A.N)
-	idx := 0
-	for i := 0; i < A.M; i++ {
-		for j := 0; j < A.N; j++ {
-			all_a_ij_times_B[idx] = *B.Scalar_mul(A.Get(i, j))
-			idx++
-		}
-	}
-
-	row_off := 0
-	col_off := 0
-	for idx, M := range all_a_ij_times_B {
-		row_off = (idx / A.N) * B.M
-		col_off = (idx % A.N) * B.N
-		for i := 0; i < M.M; i++ {
-			for j := 0; j < M.N; j++ {
-				R.Set(row_off+i, col_off+j, M.Get(i, j))
+	f := finiteField.NewField(A.Q)
+
+	for idx, a := range A.matrix {
+		row_off := (idx / A.N) * B.M
+		col_off := (idx % A.N) * B.N
+		for i := 0; i < B.M; i++ {
+			R_i := R.Row(row_off + i)[col_off : col_off+B.N]
+			for j, b := range B.Row(i) {
+				R_i[j] = uint16(f.Mul(uint32(a), uint32(b)))
 			}
 		}
 	}
@@ -232,65 +317,60 @@ func (A *Matrix) Kroenecker_product(B *Matrix) *Matrix {
 	return R
 }
 
+// CompressedSize returns the length in bytes of a compressed m x n matrix over F_q,
+// with BitLen(q) bits per element rounded up to whole bytes
+func CompressedSize(m, n, q int) int {
+	return (m*n*BitLen(q) + 7) / 8
+}
+
+// Compress packs the elements of the matrix in row-major order with
+// BitLen(M.Q) bits each, least significant bit first. The last byte is
+// padded with zero bits.
+// Returns: []byte of length CompressedSize(M.M, M.N, M.Q)
 func (M *Matrix) Compress() []byte {
-	length := int(math.Ceil(math.Log2(float64(M.Q))/float64(8))) * M.M * M.N
-	b := make([]byte, length)
-	f_byte := 0
-	f_bit := 0
-	q_bitlen := M.Get(0, 0).BitLen()
-	for i := 0; i < M.M; i++ {
-		for j := 0; j < M.N; j++ {
-			c := 0
-			v := M.Get(i, j).Value()
-			for c < q_bitlen {
-				c_prime := min(8-f_bit, q_bitlen-c)
-				b[f_byte] += byte(v % int(math.Pow(float64(2), float64(c_prime))) * int(math.Pow(float64(2), float64(f_bit))))
-				v = int(math.Floor(float64(v) / math.Pow(float64(2), float64(c_prime))))
-				c += c_prime
-				f_bit += c_prime
-				if f_bit == 8 {
-					f_bit = 0
-					f_byte++
-				}
-			}
-		}
+	return M.AppendCompress(make([]byte, 0, CompressedSize(M.M, M.N, M.Q)))
+}
+
+// AppendCompress appends the encoding of Compress to b
+// Returns: The extended slice
+func (M *Matrix) AppendCompress(b []byte) []byte {
+	width := BitLen(M.Q)
+	w := bitWriter{b: slices.Grow(b, CompressedSize(M.M, M.N, M.Q))}
+	for _, v := range M.matrix {
+		w.write(uint32(v), width)
 	}
 
-	return b
+	return w.flush()
 }
 
+// Decompress unpacks an m x n matrix over F_q encoded by Compress.
+// Values of q or more are reduced mod q, so callers that need a canonical
+// encoding compare b to the compressed result.
+// Precondition: len(b) >= CompressedSize(m, n, q)
 func Decompress(b []byte, m int, n int, q int) *Matrix {
-	M := New(m, n, q)
-	f_byte := 0
-	f_bit := 0
-	q_bitlen := M.Get(0, 0).BitLen()
-	for i := 0; i < m; i++ {
-		for j := 0; j < n; j++ {
-			c := 0
-			v := 0
-			for c < q_bitlen {
-				c_prime := min(8-f_bit, q_bitlen-c)
-				v += int(math.Floor(float64(b[f_byte])/math.Pow(float64(2), float64(f_bit)))) % int(math.Pow(2, float64(c_prime))) * int(math.Pow(2, float64(c)))
-				c += c_prime
-				f_bit += c_prime
-				if f_bit == 8 {
-					f_bit = 0
-					f_byte++
-				}
-			}
+	return DecompressInto(New(m, n, q), b)
+}
 
-			M.Set(i, j, finiteField.NewFieldElm(v, M.Q))
-		}
+// DecompressInto unpacks the M.M x M.N matrix encoded by Compress into M, like Decompress.
+// Secret keys are decoded with it, so values are reduced with Barrett's method
+// instead of a division, whose running time depends on the value.
+// Precondition: len(b) >= CompressedSize(M.M, M.N, M.Q)
+// Returns: M
+func DecompressInto(M *Matrix, b []byte) *Matrix {
+	f := finiteField.NewField(M.Q)
+	width := BitLen(M.Q)
+	r := bitReader{b: b}
+	for i := range M.matrix {
+		M.matrix[i] = uint16(f.Reduce(uint64(r.read(width))))
 	}
 
 	return M
 }
 
 func (M *Matrix) UnaryMinus() *Matrix {
-	for i := 0; i < M.M; i++ {
-		for j := 0; j < M.N; j++ {
-			M.Set(i, j, M.Get(i, j).UnaryMinus())
-		}
+	f := finiteField.NewField(M.Q)
+	for i, v := range M.matrix {
+		M.matrix[i] = uint16(f.Neg(uint32(v)))
 	}
 
 	return M
@@ -3,6 +3,7 @@ package matrix
 import (
 	"math/rand"
 	"meds/finiteField"
+	"meds/internal/racetest"
 	"os"
 	"os/exec"
 	"strconv"
@@ -49,13 +50,13 @@ func TestUpperShiftCorrect(t *testing.T) {
 		6,
 		6,
 		q,
-		[][]*finiteField.Fq{
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(1, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q)},
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(1, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q)},
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(1, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q)},
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(1, q), finiteField.NewFieldElm(0, q)},
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(1, q)},
-			{finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q), finiteField.NewFieldElm(0, q)},
+		[]uint16{
+			0, 1, 0, 0, 0, 0,
+			0, 0, 1, 0, 0, 0,
+			0, 0, 0, 1, 0, 0,
+			0, 0, 0, 0, 1, 0,
+			0, 0, 0, 0, 0, 1,
+			0, 0, 0, 0, 0, 0,
 		},
 	}
 	if !E.Equals(A) {
@@ -90,6 +91,27 @@ func TestEquals(t *testing.T) {
 	}
 }
 
+func TestConstantTimeEquals(t *testing.T) {
+	A := New(2, 3, q)
+	for i := range A.matrix {
+		A.matrix[i] = uint16(rand.Intn(q))
+	}
+	B := A.Copy()
+	if !A.ConstantTimeEquals(B) {
+		t.Errorf("Equal matrices differ")
+	}
+	for i := range B.matrix {
+		B.matrix[i] ^= 1
+		if A.ConstantTimeEquals(B) {
+			t.Errorf("Entry %v is not compared", i)
+		}
+		B.matrix[i] ^= 1
+	}
+	if A.ConstantTimeEquals(New(3, 3, q)) || A.ConstantTimeEquals(nil) {
+		t.Errorf("Matrices of other dimensions are equal")
+	}
+}
+
 func TestAdd(t *testing.T) {
 	A := New(2, 3, q)
 	B := New(2, 3, q)
@@ -166,7 +188,7 @@ func TestScalar_mul(t *testing.T) {
 		for i := 0; i < A.M; i++ {
 			for j := 0; j < A.N; j++ {
 				n := rand.Intn(int(q))
-				A.Get(i, j).Set(n)
+				A.SetValue(i, j, n)
 				E.Set(i, j, finiteField.NewFieldElm(n, q).Mul(scalar))
 			}
 		}
@@ -187,8 +209,8 @@ func TestMul(t *testing.T) {
 	for i := 0; i < A.M; i++ {
 		for j := 0; j < A.N; j++ {
 			n := rand.Intn(int(q))
-			A.Get(i, j).Set(n)
-			E.Get(i, j).Set(n)
+			A.SetValue(i, j, n)
+			E.SetValue(i, j, n)
 		}
 	}
 
@@ -202,13 +224,13 @@ func TestMul(t *testing.T) {
 	for i := 0; i < A.M; i++ {
 		for j := 0; j < A.N; j++ {
 			n := rand.Intn(int(q))
-			A.Get(i, j).Set(n)
+			A.SetValue(i, j, n)
 		}
 	}
 	for i := 0; i < B.M; i++ {
 		for j := 0; j < B.N; j++ {
 			n := rand.Intn(int(q))
-			B.Get(i, j).Set(n)
+			B.SetValue(i, j, n)
 		}
 	}
 
@@ -253,6 +275,29 @@ func TestMul(t *testing.T) {
 	}
 }
 
+func TestMulLazyReduction(t *testing.T) {
+	for _, q := range []int{4093, 2039} {
+		A := New_with_default(3, 900, q, q-1)
+		B := New_with_default(900, 2, q, q-1)
+		for i := 0; i < A.M; i++ {
+			A.SetValue(i, i, rand.Intn(q))
+		}
+
+		result := A.Mul(B)
+		for i := 0; i < result.M; i++ {
+			for j := 0; j < result.N; j++ {
+				e := finiteField.NewFieldElm(0, q)
+				for k := 0; k < A.N; k++ {
+					e = e.Add(A.Get(i, k).Mul(B.Get(k, j)))
+				}
+				if !result.Get(i, j).Equals(e) {
+					t.Errorf("q: %v (%v, %v): %v, expected %v", q, i, j, result.Get(i, j), e)
+				}
+			}
+		}
+	}
+}
+
 func TestTranspose(t *testing.T) {
 	m := 5
 	n := 2
@@ -262,8 +307,8 @@ func TestTranspose(t *testing.T) {
 	for i := 0; i < A.M; i++ {
 		for j := 0; j < A.N; j++ {
 			n := rand.Intn(int(q))
-			A.Get(i, j).Set(n)
-			E.Get(j, i).Set(n)
+			A.SetValue(i, j, n)
+			E.SetValue(j, i, n)
 		}
 	}
 
@@ -276,8 +321,8 @@ func TestTranspose(t *testing.T) {
 	for i := 0; i < A.M; i++ {
 		for j := 0; j < A.N; j++ {
 			n := rand.Intn(int(q))
-			A.Get(i, j).Set(n)
-			E.Get(i, j).Set(n)
+			A.SetValue(i, j, n)
+			E.SetValue(i, j, n)
 		}
 	}
 
@@ -286,3 +331,95 @@ func TestTranspose(t *testing.T) {
 		t.Errorf("\nResult:   %v\nE: %v\n", result.matrix, E.matrix)
 	}
 }
+
+func TestSubmatrix(t *testing.T) {
+	A := New(4, 5, q)
+	for i := 0; i < A.M; i++ {
+		for j := 0; j < A.N; j++ {
+			A.SetValue(i, j, rand.Intn(q))
+		}
+	}
+
+	S := A.Submatrix(1, 3, 2, 5)
+	if S.M != 2 || S.N != 3 {
+		t.Fatalf("Submatrix is %v x %v, expected 2 x 3", S.M, S.N)
+	}
+	for i := 0; i < S.M; i++ {
+		for j := 0; j < S.N; j++ {
+			if !S.Get(i, j).Equals(A.Get(i+1, j+2)) {
+				t.Errorf("S: %v\nA: %v", S, A)
+				return
+			}
+		}
+	}
+
+	C := A.Copy()
+	C.SetValue(0, 0, (A.GetValue(0, 0)+1)%q)
+	if C.Equals(A) {
+		t.Errorf("Copy shares memory with the matrix")
+	}
+}
+
+func TestMulInto(t *testing.T) {
+	A := New(14, 14, q)
+	B := New(14, 196, q)
+	for i := range A.matrix {
+		A.matrix[i] = uint16(rand.Intn(q))
+	}
+	for i := range B.matrix {
+		B.matrix[i] = uint16(rand.Intn(q))
+	}
+	R := New(A.M, B.N, q)
+	A.MulInto(B, R)
+	if !R.Equals(A.Mul(B)) {
+		t.Errorf("MulInto differs from Mul")
+	}
+	if racetest.Enabled {
+		t.Skip("sync.Pool drops items under the race detector")
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		A.MulInto(B, R)
+	})
+	if allocs != 0 {
+		t.Errorf("MulInto: %v allocations, expected 0", allocs)
+	}
+}
+
+func TestAppendCompress(t *testing.T) {
+	A := New(3, 5, q)
+	for i := range A.matrix {
+		A.matrix[i] = uint16(rand.Intn(q))
+	}
+	b := A.AppendCompress([]byte{0xFF, 0xFF})
+	if len(b) != 2+CompressedSize(A.M, A.N, q) || b[0] != 0xFF || b[1] != 0xFF || string(b[2:]) != string(A.Compress()) {
+		t.Errorf("AppendCompress: %v\nCompress: %v", b, A.Compress())
+	}
+}
+
+func benchmarkMul(b *testing.B, m, n, k int) {
+	A := New(m, n, q)
+	B := New(n, k, q)
+	for i := range A.matrix {
+		A.matrix[i] = uint16(rand.Intn(q))
+	}
+	for i := range B.matrix {
+		B.matrix[i] = uint16(rand.Intn(q))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		A.Mul(B)
+	}
+}
+
+func BenchmarkMul14(b *testing.B) {
+	benchmarkMul(b, 14, 14, 14)
+}
+
+func BenchmarkMul30(b *testing.B) {
+	benchmarkMul(b, 30, 30, 30)
+}
+
+func BenchmarkMulG(b *testing.B) {
+	benchmarkMul(b, 14, 14, 196)
+}
@@ -0,0 +1,88 @@
+//go:build amd64 && !purego
+
+package matrix
+
+import (
+	"math"
+	"meds/finiteField"
+
+	"golang.org/x/sys/cpu"
+)
+
+// useAVX2 selects the assembly kernel in Mul. Tests turn it off to compare
+// against the pure Go path.
+var useAVX2 = cpu.X86.HasAVX2
+
+// mulAddRowAVX2 computes acc[j] += a[l] * b[l*stride+j] for j < n and l < k
+// in 32-bit lanes without reduction.
+// Precondition: n is a multiple of 8
+//
+//go:noescape
+func mulAddRowAVX2(acc *uint32, a *uint32, b *uint16, n, k, stride int)
+
+func mul(R, A, B *Matrix) {
+	// The kernel needs at least one full vector of columns, and 32-bit lanes
+	// that hold a useful number of products, which covers 11 and 12-bit primes
+	if !useAVX2 || B.N < 8 || A.Q > 1<<12 {
+		mulGeneric(R, A, B)
+		return
+	}
+	mulAVX2(R, A, B)
+}
+
+// mulAVX2 computes R = A B with the AVX2 kernel. Products are summed in
+// 32-bit lanes for up to chunk rows of B at a time. If A.N > chunk the partial
+// sums are added to uint64 accumulators. Each entry is reduced once at the end.
+// Columns that do not fill a vector are covered by one more vector over the
+// last 8 columns.
+func mulAVX2(R, A, B *Matrix) {
+	f := finiteField.NewField(A.Q)
+	chunk := int(math.MaxUint32 / uint64((A.Q-1)*(A.Q-1)))
+	n_vec := B.N &^ 7
+	tail := B.N - 8
+	scratch := mulScratchPool.Get().(*mulScratch)
+	defer putMulScratch(scratch)
+	scratch.acc = grow(scratch.acc, n_vec+8)
+	scratch.a = grow(scratch.a, A.N)
+	acc, a := scratch.acc, scratch.a
+	var acc64 []uint64
+	if A.N > chunk {
+		scratch.acc64 = grow(scratch.acc64, B.N)
+		acc64 = scratch.acc64
+	}
+	// col returns the index in acc of column j
+	col := func(j int) int {
+		if j < n_vec {
+			return j
+		}
+		return n_vec + j - tail
+	}
+
+	for i := 0; i < R.M; i++ {
+		for k, v := range A.Row(i) {
+			a[k] = uint32(v)
+		}
+		clear(acc64)
+		for k0 := 0; k0 < A.N; k0 += chunk {
+			k1 := min(k0+chunk, A.N)
+			clear(acc)
+			mulAddRowAVX2(&acc[0], &a[k0], &B.matrix[k0*B.N], n_vec, k1-k0, B.N)
+			if n_vec < B.N {
+				mulAddRowAVX2(&acc[n_vec], &a[k0], &B.matrix[k0*B.N+tail], 8, k1-k0, B.N)
+			}
+			for j := range acc64 {
+				acc64[j] += uint64(acc[col(j)])
+			}
+		}
+		R_i := R.Row(i)
+		if acc64 != nil {
+			for j, v := range acc64 {
+				R_i[j] = uint16(f.Reduce(v))
+			}
+			continue
+		}
+		for j := range R_i {
+			R_i[j] = uint16(f.Reduce(uint64(acc[col(j)])))
+		}
+	}
+}
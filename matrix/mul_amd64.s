@@ -0,0 +1,76 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func mulAddRowAVX2(acc *uint32, a *uint32, b *uint16, n, k, stride int)
+TEXT ·mulAddRowAVX2(SB), NOSPLIT, $0-48
+	MOVQ acc+0(FP), DI
+	MOVQ a+8(FP), SI
+	MOVQ b+16(FP), DX
+	MOVQ n+24(FP), CX
+	MOVQ k+32(FP), R8
+	MOVQ stride+40(FP), R9
+	SHLQ $1, R9
+	XORQ R10, R10
+
+loop_j16:
+	// 16 columns j, ..., j+15 per iteration, accumulated in Y0 and Y3
+	LEAQ    16(R10), AX
+	CMPQ    AX, CX
+	JG      loop_j8
+	VMOVDQU (DI)(R10*4), Y0
+	VMOVDQU 32(DI)(R10*4), Y3
+	LEAQ    (DX)(R10*2), R11
+	MOVQ    SI, R12
+	MOVQ    R8, R13
+
+loop_k16:
+	TESTQ R13, R13
+	JZ    store16
+	VPBROADCASTD (R12), Y2
+	VPMOVZXWD    (R11), Y1
+	VPMOVZXWD    16(R11), Y4
+	VPMULLD      Y1, Y2, Y1
+	VPMULLD      Y4, Y2, Y4
+	VPADDD       Y1, Y0, Y0
+	VPADDD       Y4, Y3, Y3
+	ADDQ         R9, R11
+	ADDQ         $4, R12
+	DECQ         R13
+	JMP          loop_k16
+
+store16:
+	VMOVDQU Y0, (DI)(R10*4)
+	VMOVDQU Y3, 32(DI)(R10*4)
+	MOVQ    AX, R10
+	JMP     loop_j16
+
+loop_j8:
+	// 8 columns j, ..., j+7 per iteration, accumulated in Y0
+	CMPQ    R10, CX
+	JGE     done
+	VMOVDQU (DI)(R10*4), Y0
+	LEAQ    (DX)(R10*2), R11
+	MOVQ    SI, R12
+	MOVQ    R8, R13
+
+loop_k8:
+	TESTQ R13, R13
+	JZ    store8
+	VPMOVZXWD    (R11), Y1
+	VPBROADCASTD (R12), Y2
+	VPMULLD      Y1, Y2, Y1
+	VPADDD       Y1, Y0, Y0
+	ADDQ         R9, R11
+	ADDQ         $4, R12
+	DECQ         R13
+	JMP          loop_k8
+
+store8:
+	VMOVDQU Y0, (DI)(R10*4)
+	ADDQ    $8, R10
+	JMP     loop_j8
+
+done:
+	VZEROUPPER
+	RET
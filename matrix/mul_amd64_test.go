@@ -0,0 +1,67 @@
+//go:build amd64 && !purego
+
+package matrix
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomMatrix(m, n, q int) *Matrix {
+	A := New(m, n, q)
+	for i := range A.matrix {
+		A.matrix[i] = uint16(rand.Intn(q))
+	}
+	return A
+}
+
+func TestMulAVX2(t *testing.T) {
+	if !useAVX2 {
+		t.Skip("CPU does not support AVX2")
+	}
+	dims := [][3]int{{1, 1, 8}, {3, 3, 9}, {14, 14, 14}, {14, 14, 196}, {22, 22, 22}, {30, 30, 30}, {30, 30, 900}, {2, 600, 17}, {3, 1100, 24}}
+	for _, q := range []int{4093, 2039} {
+		for _, d := range dims {
+			A := randomMatrix(d[0], d[1], q)
+			B := randomMatrix(d[1], d[2], q)
+			R := New(A.M, B.N, q)
+			E := New(A.M, B.N, q)
+			mulAVX2(R, A, B)
+			mulGeneric(E, A, B)
+			if !R.Equals(E) {
+				t.Errorf("q: %v, %v x %v times %v x %v:\nR: %v\nE: %v", q, A.M, A.N, B.M, B.N, R, E)
+			}
+		}
+		// All entries q-1 give the largest possible sums in the 32-bit lanes
+		A := New_with_default(4, 1000, q, q-1)
+		B := New_with_default(1000, 16, q, q-1)
+		R := New(A.M, B.N, q)
+		E := New(A.M, B.N, q)
+		mulAVX2(R, A, B)
+		mulGeneric(E, A, B)
+		if !R.Equals(E) {
+			t.Errorf("q: %v, maximal entries:\nR: %v\nE: %v", q, R, E)
+		}
+	}
+}
+
+func BenchmarkMulGeneric14(b *testing.B) {
+	useAVX2_prev := useAVX2
+	useAVX2 = false
+	defer func() { useAVX2 = useAVX2_prev }()
+	benchmarkMul(b, 14, 14, 14)
+}
+
+func BenchmarkMulGeneric30(b *testing.B) {
+	useAVX2_prev := useAVX2
+	useAVX2 = false
+	defer func() { useAVX2 = useAVX2_prev }()
+	benchmarkMul(b, 30, 30, 30)
+}
+
+func BenchmarkMulGenericG(b *testing.B) {
+	useAVX2_prev := useAVX2
+	useAVX2 = false
+	defer func() { useAVX2 = useAVX2_prev }()
+	benchmarkMul(b, 14, 14, 196)
+}
@@ -0,0 +1,7 @@
+//go:build !amd64 || purego
+
+package matrix
+
+func mul(R, A, B *Matrix) {
+	mulGeneric(R, A, B)
+}
@@ -0,0 +1,62 @@
+package meds
+
+// BatchResult is the outcome of verifying one signed message of a batch
+type BatchResult struct {
+	// Msg is the message if the signature is valid, otherwise nil
+	Msg []byte
+	// Err is nil if the signature is valid
+	Err error
+}
+
+// VerifyBatch verifies the signed messages msgs_s using the parameter set selected with ParameterSetup
+// Returns: One result per signed message, in order
+func VerifyBatch(pk []byte, msgs_s [][]byte) []BatchResult {
+	p, err := loadDefaultScheme()
+	if err != nil {
+		results := make([]BatchResult, len(msgs_s))
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+	return p.VerifyBatch(pk, msgs_s)
+}
+
+// VerifyBatch verifies the signed messages msgs_s, each as returned by Sign,
+// under the public key pk. The key is decoded and G_0 is expanded once for
+// the whole batch. The signed messages are verified concurrently, and the
+// workers of the scheme are split between them.
+// Returns: One result per signed message, in order, equal to what Verify returns for it
+func (p *Scheme) VerifyBatch(pk []byte, msgs_s [][]byte) []BatchResult {
+	prep, err := p.NewPreparedPublicKey(pk)
+	if err != nil {
+		// Verify still reports a signed message that is too short first
+		results := make([]BatchResult, len(msgs_s))
+		for i, msg_s := range msgs_s {
+			_, _, results[i].Err = p.SplitSignedMessage(msg_s)
+			if results[i].Err == nil {
+				results[i].Err = err
+			}
+		}
+		return results
+	}
+	return prep.VerifyBatch(msgs_s)
+}
+
+// VerifyBatch verifies the signed messages msgs_s, each as returned by Sign,
+// concurrently. The workers of the scheme are split between them.
+// Returns: One result per signed message, in order, equal to what Open returns for it
+func (prep *PreparedPublicKey) VerifyBatch(msgs_s [][]byte) []BatchResult {
+	p := prep.pub.scheme
+	results := make([]BatchResult, len(msgs_s))
+	// Every signed message gets an equal share of the workers for its rounds
+	pub := *prep.pub
+	pub.scheme = p.WithWorkers(max(1, p.Workers()/max(1, len(msgs_s))))
+	item := &PreparedPublicKey{&pub, prep.G_0}
+	p.forEachRound(len(msgs_s), func(i int) error {
+		results[i].Msg, results[i].Err = item.Open(msgs_s[i])
+		// A failed item must not stop the others
+		return nil
+	})
+	return results
+}
@@ -0,0 +1,80 @@
+package meds
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestVerifyBatch(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	_, sk_other, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var msgs_s [][]byte
+	for i := 0; i < 4; i++ {
+		msg_s, err := scheme.Sign(nil, sk, []byte(fmt.Sprintf("message %v", i)))
+		if err != nil {
+			test.Fatal(err)
+		}
+		msgs_s = append(msgs_s, msg_s)
+	}
+	other, err := scheme.Sign(nil, sk_other, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	changed := bytes.Clone(msgs_s[0])
+	changed[len(changed)-1] ^= 1
+	// mu_0 = 0 is not invertible
+	non_invertible := bytes.Clone(msgs_s[1])
+	for i := 0; i < scheme.l_f_mm; i++ {
+		non_invertible[i] = 0
+	}
+	msgs_s = append(msgs_s, other, changed, non_invertible, msgs_s[2][:10], nil)
+
+	malformed := bytes.Clone(pk)
+	for i := scheme.l_pub_seed; i < len(malformed); i++ {
+		malformed[i] = 0xFF
+	}
+	for _, key := range [][]byte{pk, pk[:10], malformed} {
+		for _, workers := range []int{1, 3, 16} {
+			results := scheme.WithWorkers(workers).VerifyBatch(key, msgs_s)
+			if len(results) != len(msgs_s) {
+				test.Fatalf("%v results for %v signed messages\n", len(results), len(msgs_s))
+			}
+			for i, msg_s := range msgs_s {
+				E_msg, E_err := scheme.Verify(key, msg_s)
+				if !bytes.Equal(results[i].Msg, E_msg) || fmt.Sprint(results[i].Err) != fmt.Sprint(E_err) {
+					test.Errorf("%v workers, item %v:\nResult: (%q, %v)\nE:      (%q, %v)\n", workers, i, results[i].Msg, results[i].Err, E_msg, E_err)
+				}
+			}
+		}
+	}
+
+	if results := scheme.VerifyBatch(pk, nil); len(results) != 0 {
+		test.Errorf("Results for an empty batch: %v\n", results)
+	}
+}
+
+func BenchmarkVerifyBatch9923(b *testing.B) {
+	scheme, _ := NewScheme(9923)
+	pk, sk, _ := scheme.KeyGen(nil)
+	msgs_s := make([][]byte, 8)
+	for i := range msgs_s {
+		msgs_s[i], _ = scheme.Sign(nil, sk, msg)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, result := range scheme.VerifyBatch(pk, msgs_s) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+		}
+	}
+}
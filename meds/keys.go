@@ -0,0 +1,403 @@
+package meds
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"meds/matrix"
+	"sync"
+)
+
+var (
+	// errPublicKeyNoScheme is returned for a zero PublicKey, which has no parameter set
+	errPublicKeyNoScheme = errors.New("meds: public key has no parameter set")
+	// errPrivateKeyNoScheme is returned for a zero PrivateKey, which has no parameter set
+	errPrivateKeyNoScheme = errors.New("meds: secret key has no parameter set")
+)
+
+// PublicKey is a MEDS public key parsed into its parts
+type PublicKey struct {
+	scheme    *Scheme
+	Sigma_G_0 []byte
+	// G holds the public codes G_1, ..., G_{s-1} in systematic form
+	G []*matrix.Matrix
+}
+
+// PrivateKey is a MEDS secret key parsed into its parts.
+// It implements crypto.Signer. Sign and MarshalBinary return an error if
+// the fields no longer have the sizes of the parameter set.
+type PrivateKey struct {
+	scheme    *Scheme
+	Delta     []byte
+	Sigma_G_0 []byte
+	A_inv     []*matrix.Matrix
+	B_inv     []*matrix.Matrix
+
+	public_once sync.Once
+	public      *PublicKey
+}
+
+// NewPublicKey parses the public key bytes pk as returned by KeyGen
+// Returns: *PublicKey, or an error if pk is not a public key of the parameter set
+func (p *Scheme) NewPublicKey(pk []byte) (*PublicKey, error) {
+	return p.parsePublicKey(pk)
+}
+
+// NewPrivateKey parses the key pair (pk, sk) as returned by KeyGen
+// Returns: *PrivateKey, or an error if the keys are not keys of the parameter set
+func (p *Scheme) NewPrivateKey(pk, sk []byte) (*PrivateKey, error) {
+	pub, err := p.parsePublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := p.parsePrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	priv.public_once.Do(func() { priv.public = pub })
+	return priv, nil
+}
+
+// NewPrivateKeyFromSeed derives the key pair from the compact secret key delta.
+// A_inv and B_inv are derived once and kept in the returned key.
+// Returns: *PrivateKey, or an error if delta is not a seed of the parameter set
+func (p *Scheme) NewPrivateKeyFromSeed(delta []byte) (*PrivateKey, error) {
+	pk, sk, err := p.KeyGenFromSeed(delta)
+	if err != nil {
+		return nil, err
+	}
+	// The key keeps its own copies, so the encoding of the secrets is wiped
+	defer clear(sk)
+	return p.NewPrivateKey(pk, sk)
+}
+
+// GenerateKey generates a new key pair with the secret seed read from rng.
+// If rng is nil, crypto/rand.Reader is used.
+// Returns: *PrivateKey
+func (p *Scheme) GenerateKey(rng io.Reader) (*PrivateKey, error) {
+	pk, sk, err := p.KeyGen(rng)
+	if err != nil {
+		return nil, err
+	}
+	// The key keeps its own copies, so the encoding of the secrets is wiped
+	defer clear(sk)
+	return p.NewPrivateKey(pk, sk)
+}
+
+func (p *Scheme) parsePublicKey(pk []byte) (*PublicKey, error) {
+	if len(pk) != p.l_pk {
+		return nil, fmt.Errorf("%w: public key is %v bytes, expected %v", ErrWrongLength, len(pk), p.l_pk)
+	}
+	pub := &PublicKey{
+		scheme:    p,
+		Sigma_G_0: bytes.Clone(pk[:p.l_pub_seed]),
+		G:         make([]*matrix.Matrix, p.s-1),
+	}
+	f_pk := p.l_pub_seed
+	for i := 0; i < p.s-1; i++ {
+		b := pk[f_pk : f_pk+p.l_G_i]
+		pub.G[i] = DecompressG(b, p.q, p.m, p.n, p.k)
+		if !bytes.Equal(CompressG(pub.G[i], p.m, p.n, p.k), b) {
+			return nil, fmt.Errorf("%w: G_%v is not canonically encoded", ErrMalformedKey, i+1)
+		}
+		f_pk += p.l_G_i
+	}
+	return pub, nil
+}
+
+func (p *Scheme) parsePrivateKey(sk []byte) (*PrivateKey, error) {
+	if len(sk) == p.l_sec_seed {
+		return p.NewPrivateKeyFromSeed(sk)
+	}
+	if len(sk) != p.l_sk {
+		return nil, fmt.Errorf("%w: secret key is %v bytes, expected %v or %v", ErrWrongLength, len(sk), p.l_sk, p.l_sec_seed)
+	}
+	priv := &PrivateKey{
+		scheme:    p,
+		Delta:     bytes.Clone(sk[:p.l_sec_seed]),
+		Sigma_G_0: bytes.Clone(sk[p.l_sec_seed : p.l_sec_seed+p.l_pub_seed]),
+		A_inv:     make([]*matrix.Matrix, p.s-1),
+		B_inv:     make([]*matrix.Matrix, p.s-1),
+	}
+	// compressed holds the re-encoding of one matrix for the canonical check
+	compressed := make([]byte, 0, max(p.l_f_mm, p.l_f_nn))
+	defer clear(compressed[:cap(compressed)])
+	f_sk := p.l_sec_seed + p.l_pub_seed
+	for i := 0; i < p.s-1; i++ {
+		b := sk[f_sk : f_sk+p.l_f_mm]
+		priv.A_inv[i] = matrix.Decompress(b, p.m, p.m, p.q)
+		if !bytes.Equal(priv.A_inv[i].AppendCompress(compressed[:0]), b) {
+			return nil, fmt.Errorf("%w: A_inv_%v is not canonically encoded", ErrMalformedKey, i+1)
+		}
+		f_sk += p.l_f_mm
+	}
+	for i := 0; i < p.s-1; i++ {
+		b := sk[f_sk : f_sk+p.l_f_nn]
+		priv.B_inv[i] = matrix.Decompress(b, p.n, p.n, p.q)
+		if !bytes.Equal(priv.B_inv[i].AppendCompress(compressed[:0]), b) {
+			return nil, fmt.Errorf("%w: B_inv_%v is not canonically encoded", ErrMalformedKey, i+1)
+		}
+		f_sk += p.l_f_nn
+	}
+	return priv, nil
+}
+
+// schemeForSize finds the built-in parameter set whose size(scheme) is l
+func schemeForSize(l int, size func(*Scheme) int) (*Scheme, error) {
+	for _, set := range ParameterSets() {
+		p, err := NewScheme(set)
+		if err != nil {
+			return nil, err
+		}
+		if size(p) == l {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v bytes does not match any parameter set", ErrWrongLength, l)
+}
+
+// Scheme returns the parameter set of the key
+func (pub *PublicKey) Scheme() *Scheme {
+	return pub.scheme
+}
+
+// MarshalBinary encodes the public key as sigma_G_0 || G_1 || ... || G_{s-1}
+func (pub *PublicKey) MarshalBinary() ([]byte, error) {
+	if pub == nil || pub.scheme == nil {
+		return nil, errPublicKeyNoScheme
+	}
+	p := pub.scheme
+	if len(pub.Sigma_G_0) != p.l_pub_seed || len(pub.G) != p.s-1 {
+		return nil, fmt.Errorf("%w: public key does not match MEDS-%v", ErrWrongLength, p.set)
+	}
+	pk := make([]byte, 0, p.l_pk)
+	pk = append(pk, pub.Sigma_G_0...)
+	for _, G := range pub.G {
+		pk = append(pk, CompressG(G, p.m, p.n, p.k)...)
+	}
+	return pk, nil
+}
+
+// UnmarshalBinary decodes a public key. If pub has no parameter set yet,
+// it is selected among the built-in sets by the length of pk.
+func (pub *PublicKey) UnmarshalBinary(pk []byte) error {
+	p := pub.scheme
+	if p == nil {
+		var err error
+		p, err = schemeForSize(len(pk), (*Scheme).PublicKeySize)
+		if err != nil {
+			return err
+		}
+	}
+	parsed, err := p.parsePublicKey(pk)
+	if err != nil {
+		return err
+	}
+	*pub = *parsed
+	return nil
+}
+
+// Bytes returns the encoded public key, or nil for a nil or zero key
+func (pub *PublicKey) Bytes() []byte {
+	pk, _ := pub.MarshalBinary()
+	return pk
+}
+
+// Equal reports whether pub and x are the same public key of the same parameter set
+func (pub *PublicKey) Equal(x crypto.PublicKey) bool {
+	xx, ok := x.(*PublicKey)
+	if !ok || pub == nil || xx == nil || pub.scheme == nil || xx.scheme == nil {
+		return false
+	}
+	return pub.scheme.set == xx.scheme.set && bytes.Equal(pub.Bytes(), xx.Bytes())
+}
+
+// Verify verifies the detached signature sig on msg
+// Returns: nil if the signature is valid, otherwise an error
+func (pub *PublicKey) Verify(msg, sig []byte) error {
+	if pub == nil || pub.scheme == nil {
+		return errPublicKeyNoScheme
+	}
+	signature, err := pub.scheme.parseSignature(sig)
+	if err != nil {
+		return err
+	}
+	return pub.scheme.verifyDetached(pub, bytes.NewReader(msg), signature)
+}
+
+// VerifyReader verifies the detached signature sig on the message read from r until EOF
+// Returns: nil if the signature is valid, otherwise an error
+func (pub *PublicKey) VerifyReader(r io.Reader, sig []byte) error {
+	if pub == nil || pub.scheme == nil {
+		return errPublicKeyNoScheme
+	}
+	signature, err := pub.scheme.parseSignature(sig)
+	if err != nil {
+		return err
+	}
+	return pub.scheme.verifyDetached(pub, r, signature)
+}
+
+// Scheme returns the parameter set of the key
+func (priv *PrivateKey) Scheme() *Scheme {
+	return priv.scheme
+}
+
+// check verifies that the exported fields of priv still have the lengths and
+// matrix dimensions of its parameter set, since callers may have changed them
+// Returns: nil, or an error describing the first mismatch
+func (priv *PrivateKey) check() error {
+	if priv.scheme == nil {
+		return errPrivateKeyNoScheme
+	}
+	p := priv.scheme
+	if len(priv.Delta) != p.l_sec_seed || len(priv.Sigma_G_0) != p.l_pub_seed || len(priv.A_inv) != p.s-1 || len(priv.B_inv) != p.s-1 {
+		return fmt.Errorf("%w: secret key does not match MEDS-%v", ErrWrongLength, p.set)
+	}
+	for i := 0; i < p.s-1; i++ {
+		if !hasShape(priv.A_inv[i], p.m, p.q) {
+			return fmt.Errorf("%w: A_inv_%v is not a %v x %v matrix over F_%v", ErrMalformedKey, i+1, p.m, p.m, p.q)
+		}
+		if !hasShape(priv.B_inv[i], p.n, p.q) {
+			return fmt.Errorf("%w: B_inv_%v is not a %v x %v matrix over F_%v", ErrMalformedKey, i+1, p.n, p.n, p.q)
+		}
+	}
+	return nil
+}
+
+// hasShape reports whether M is a d x d matrix over F_q
+func hasShape(M *matrix.Matrix, d, q int) bool {
+	return M != nil && M.M == d && M.N == d && M.Q == q && len(M.Data()) == d*d
+}
+
+// MarshalBinary encodes the secret key as
+// delta || sigma_G_0 || A_inv_1 || ... || A_inv_{s-1} || B_inv_1 || ... || B_inv_{s-1}
+func (priv *PrivateKey) MarshalBinary() ([]byte, error) {
+	err := priv.check()
+	if err != nil {
+		return nil, err
+	}
+	p := priv.scheme
+	sk := make([]byte, 0, p.l_sk)
+	sk = append(sk, priv.Delta...)
+	sk = append(sk, priv.Sigma_G_0...)
+	for _, A_inv := range priv.A_inv {
+		sk = append(sk, A_inv.Compress()...)
+	}
+	for _, B_inv := range priv.B_inv {
+		sk = append(sk, B_inv.Compress()...)
+	}
+	return sk, nil
+}
+
+// UnmarshalBinary decodes an expanded or a compact secret key. If priv has no
+// parameter set yet, it is selected among the built-in sets by the length of sk,
+// which only works for expanded keys.
+// The public key is derived from delta the first time Public is called.
+func (priv *PrivateKey) UnmarshalBinary(sk []byte) error {
+	p := priv.scheme
+	if p == nil {
+		var err error
+		p, err = schemeForSize(len(sk), (*Scheme).PrivateKeySize)
+		if err != nil {
+			return err
+		}
+	}
+	parsed, err := p.parsePrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	priv.scheme = parsed.scheme
+	priv.Delta = parsed.Delta
+	priv.Sigma_G_0 = parsed.Sigma_G_0
+	priv.A_inv = parsed.A_inv
+	priv.B_inv = parsed.B_inv
+	priv.public_once = sync.Once{}
+	priv.public = nil
+	if parsed.public != nil {
+		// A compact key has already been expanded together with its public key
+		priv.public_once.Do(func() { priv.public = parsed.public })
+	}
+	return nil
+}
+
+// Bytes returns the encoded secret key
+func (priv *PrivateKey) Bytes() []byte {
+	sk, _ := priv.MarshalBinary()
+	return sk
+}
+
+// Seed returns the compact encoding of the secret key, the secret seed delta
+func (priv *PrivateKey) Seed() []byte {
+	return bytes.Clone(priv.Delta)
+}
+
+// Public returns the *PublicKey corresponding to priv, or nil if priv has
+// no parameter set or its seed does not give a key pair
+func (priv *PrivateKey) Public() crypto.PublicKey {
+	priv.public_once.Do(func() {
+		if priv.scheme == nil {
+			return
+		}
+		pk, _, err := priv.scheme.KeyGenFromSeed(priv.Delta)
+		if err != nil {
+			return
+		}
+		priv.public, _ = priv.scheme.parsePublicKey(pk)
+	})
+	// A nil *PublicKey would be a non-nil crypto.PublicKey
+	if priv.public == nil {
+		return nil
+	}
+	return priv.public
+}
+
+// Equal reports whether priv and x are the same secret key of the same parameter set
+func (priv *PrivateKey) Equal(x crypto.PrivateKey) bool {
+	xx, ok := x.(*PrivateKey)
+	if !ok || xx == nil || priv.scheme == nil || xx.scheme == nil {
+		return false
+	}
+	if priv.scheme.set != xx.scheme.set {
+		return false
+	}
+	a, b := priv.Bytes(), xx.Bytes()
+	defer clear(a)
+	defer clear(b)
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// Sign signs msg and returns a detached signature. MEDS hashes the message
+// itself, so opts.HashFunc() must be zero and msg is the whole message.
+// The signing randomness is read from rand, or from crypto/rand.Reader if rand is nil.
+func (priv *PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("meds: cannot sign a pre-hashed message")
+	}
+	err := priv.check()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := priv.scheme.signDetached(rand, priv, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	return signature.MarshalBinary()
+}
+
+// SignReader signs the message read from r until EOF and returns a detached signature.
+// The signing randomness is read from rand, or from crypto/rand.Reader if rand is nil.
+func (priv *PrivateKey) SignReader(rand io.Reader, r io.Reader) ([]byte, error) {
+	err := priv.check()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := priv.scheme.signDetached(rand, priv, r)
+	if err != nil {
+		return nil, err
+	}
+	return signature.MarshalBinary()
+}
@@ -0,0 +1,304 @@
+package meds
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"meds/matrix"
+	"testing"
+)
+
+func TestSigner(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	priv, err := scheme.GenerateKey(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var signer crypto.Signer = priv
+	sig, err := signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	if err != nil {
+		test.Fatal(err)
+	}
+	pub := signer.Public().(*PublicKey)
+	if err := pub.Verify(msg, sig); err != nil {
+		test.Errorf("Invalid signature: %v\n", err)
+	}
+	if err := pub.Verify([]byte("Another message"), sig); !errors.Is(err, ErrInvalidSignature) {
+		test.Errorf("Signature valid for another message: %v\n", err)
+	}
+	if _, err := signer.Sign(rand.Reader, msg, crypto.SHA256); err == nil {
+		test.Errorf("Signing a pre-hashed message should fail\n")
+	}
+}
+
+func TestKeyEqual(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	priv, err := scheme.NewPrivateKey(pk, sk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	pub, err := scheme.NewPublicKey(pk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !pub.Equal(priv.Public()) {
+		test.Errorf("Public keys are not equal\n")
+	}
+	other, err := scheme.GenerateKey(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if pub.Equal(other.Public()) || priv.Equal(other) {
+		test.Errorf("Different keys are equal\n")
+	}
+	if _, err := scheme.NewPublicKey(pk[1:]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short public key accepted: %v\n", err)
+	}
+}
+
+// TestKeyDiffersInLastByte checks that secret keys differing only at the end
+// of their encoding are told apart, which ConstantTimeCompare must still do
+func TestKeyDiffersInLastByte(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	priv, err := scheme.NewPrivateKey(pk, sk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	other, err := scheme.NewPrivateKey(pk, sk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !priv.Equal(other) {
+		test.Errorf("Equal secret keys differ\n")
+	}
+	last := other.B_inv[len(other.B_inv)-1]
+	last.SetValue(scheme.n-1, scheme.n-1, (last.GetValue(scheme.n-1, scheme.n-1)+1)%scheme.q)
+	if priv.Equal(other) {
+		test.Errorf("Secret keys differing in the last element are equal\n")
+	}
+
+	sk[len(sk)-1] ^= 1
+	if err := scheme.CheckKeyPair(pk, sk); !errors.Is(err, ErrKeyMismatch) {
+		test.Errorf("Secret key differing in the last byte accepted: %v\n", err)
+	}
+}
+
+func TestZeroKey(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	priv, err := scheme.GenerateKey(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	pub := priv.Public().(*PublicKey)
+	sig, err := priv.Sign(nil, msg, crypto.Hash(0))
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	// Zero keys have no parameter set and must fail instead of panicking
+	var zero_pub PublicKey
+	var zero_priv PrivateKey
+	if err := zero_pub.Verify(msg, sig); err == nil {
+		test.Errorf("Zero public key verified a signature\n")
+	}
+	if err := zero_pub.VerifyReader(bytes.NewReader(msg), sig); err == nil {
+		test.Errorf("Zero public key verified a signature\n")
+	}
+	if zero_pub.Equal(pub) || pub.Equal(&zero_pub) || zero_pub.Equal(&zero_pub) {
+		test.Errorf("Zero public key is equal to a key\n")
+	}
+	if zero_priv.Equal(priv) || priv.Equal(&zero_priv) || zero_priv.Equal(&zero_priv) {
+		test.Errorf("Zero secret key is equal to a key\n")
+	}
+	if _, err := zero_priv.Sign(nil, msg, crypto.Hash(0)); err == nil {
+		test.Errorf("Zero secret key signed a message\n")
+	}
+	if _, err := zero_priv.SignReader(nil, bytes.NewReader(msg)); err == nil {
+		test.Errorf("Zero secret key signed a message\n")
+	}
+	if zero_priv.Public() != nil {
+		test.Errorf("Zero secret key has a public key\n")
+	}
+	bad_seed := PrivateKey{scheme: scheme, Delta: []byte{1}}
+	if bad_seed.Public() != nil {
+		test.Errorf("Secret key with a short seed has a public key\n")
+	}
+
+	var nil_pub *PublicKey
+	if err := nil_pub.Verify(msg, sig); err == nil {
+		test.Errorf("Nil public key verified a signature\n")
+	}
+	if err := nil_pub.VerifyReader(bytes.NewReader(msg), sig); err == nil {
+		test.Errorf("Nil public key verified a signature\n")
+	}
+	if _, err := nil_pub.MarshalBinary(); err == nil || nil_pub.Bytes() != nil {
+		test.Errorf("Nil public key has an encoding\n")
+	}
+	if nil_pub.Equal(pub) || pub.Equal(nil_pub) {
+		test.Errorf("Nil public key is equal to a key\n")
+	}
+}
+
+// TestModifiedPrivateKey checks that signing with resized or replaced secret key fields fails instead of panicking
+func TestModifiedPrivateKey(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	modifications := map[string]func(priv *PrivateKey){
+		"short A_inv":   func(priv *PrivateKey) { priv.A_inv = priv.A_inv[:1] },
+		"nil B_inv_1":   func(priv *PrivateKey) { priv.B_inv[0] = nil },
+		"wide A_inv_2":  func(priv *PrivateKey) { priv.A_inv[1] = matrix.New(scheme.m, scheme.m+1, scheme.q) },
+		"small B_inv_3": func(priv *PrivateKey) { priv.B_inv[2] = matrix.New(scheme.n-1, scheme.n-1, scheme.q) },
+		"resized A_inv": func(priv *PrivateKey) { priv.A_inv[0].M, priv.A_inv[0].N = scheme.m+1, scheme.m+1 },
+		"other q":       func(priv *PrivateKey) { priv.B_inv[1] = matrix.New(scheme.n, scheme.n, 2039) },
+		"short Delta":   func(priv *PrivateKey) { priv.Delta = priv.Delta[:4] },
+	}
+	for name, modify := range modifications {
+		priv, err := scheme.GenerateKey(nil)
+		if err != nil {
+			test.Fatal(err)
+		}
+		modify(priv)
+		if _, err := priv.Sign(nil, msg, crypto.Hash(0)); err == nil {
+			test.Errorf("%v: Sign succeeded\n", name)
+		}
+		if _, err := priv.SignReader(nil, bytes.NewReader(msg)); err == nil {
+			test.Errorf("%v: SignReader succeeded\n", name)
+		}
+		if _, err := priv.MarshalBinary(); err == nil {
+			test.Errorf("%v: MarshalBinary succeeded\n", name)
+		}
+	}
+}
+
+func TestKeyEncoding(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var pub PublicKey
+	if err := pub.UnmarshalBinary(pk); err != nil {
+		test.Fatal(err)
+	}
+	if pub.Scheme().ParameterSet() != 1 || !bytes.Equal(pub.Bytes(), pk) {
+		test.Errorf("Public key round trip failed\n")
+	}
+	var priv PrivateKey
+	if err := priv.UnmarshalBinary(sk); err != nil {
+		test.Fatal(err)
+	}
+	if priv.Scheme().ParameterSet() != 1 || !bytes.Equal(priv.Bytes(), sk) {
+		test.Errorf("Secret key round trip failed\n")
+	}
+	if !pub.Equal(priv.Public()) {
+		test.Errorf("Derived public key differs\n")
+	}
+	if err := pub.UnmarshalBinary(pk[:len(pk)-1]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short public key accepted: %v\n", err)
+	}
+	if err := new(PrivateKey).UnmarshalBinary(sk[:7]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short secret key accepted: %v\n", err)
+	}
+	// 0xFFFF is not an element of GF(q)
+	bad := bytes.Clone(sk)
+	bad[scheme.l_sec_seed+scheme.l_pub_seed] = 0xFF
+	bad[scheme.l_sec_seed+scheme.l_pub_seed+1] = 0xFF
+	if _, err := scheme.NewPrivateKey(pk, bad); !errors.Is(err, ErrMalformedKey) {
+		test.Errorf("Non-canonical secret key accepted: %v\n", err)
+	}
+	bad = bytes.Clone(pk)
+	bad[scheme.l_pub_seed] = 0xFF
+	bad[scheme.l_pub_seed+1] = 0xFF
+	if _, err := scheme.NewPublicKey(bad); !errors.Is(err, ErrMalformedKey) {
+		test.Errorf("Non-canonical public key accepted: %v\n", err)
+	}
+}
+
+func TestCompactKey(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	delta := []byte("0123456789abcdef0123456789abcdef")
+	pk, sk, err := scheme.KeyGenFromSeed(delta)
+	if err != nil {
+		test.Fatal(err)
+	}
+	compact, err := scheme.CompactPrivateKey(sk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(compact) != scheme.CompactPrivateKeySize() || !bytes.Equal(compact, delta) {
+		test.Errorf("Compact key is not the seed\n")
+	}
+	expanded, err := scheme.ExpandPrivateKey(compact)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !bytes.Equal(expanded, sk) {
+		test.Errorf("Expanded key differs from KeyGen\n")
+	}
+	if err := scheme.CheckKeyPair(pk, compact); err != nil {
+		test.Errorf("%v\n", err)
+	}
+
+	sig, err := scheme.SignDetached(bytes.NewReader(delta), sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig_compact, err := scheme.SignDetached(bytes.NewReader(delta), compact, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !bytes.Equal(sig, sig_compact) {
+		test.Errorf("Signatures with the compact and expanded key differ\n")
+	}
+
+	priv, err := scheme.NewPrivateKeyFromSeed(compact)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var priv_prime PrivateKey
+	priv_prime.scheme = scheme
+	if err := priv_prime.UnmarshalBinary(priv.Seed()); err != nil {
+		test.Fatal(err)
+	}
+	if !priv.Equal(&priv_prime) || !bytes.Equal(priv_prime.Bytes(), sk) {
+		test.Errorf("Compact key round trip failed\n")
+	}
+	pub, _ := scheme.NewPublicKey(pk)
+	if !pub.Equal(priv_prime.Public()) {
+		test.Errorf("Public key of the compact key differs\n")
+	}
+	if _, err := scheme.ExpandPrivateKey(compact[1:]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short compact key accepted: %v\n", err)
+	}
+}
+
+// TestSizes checks the key and signature sizes of the specification. The
+// name of a parameter set is its public key size.
+func TestSizes(test *testing.T) {
+	sizes := map[int][2]int{
+		9923:   {1828, 9896},
+		13220:  {2416, 12976},
+		41711:  {4420, 41080},
+		69497:  {7324, 54736},
+		134180: {9968, 132528},
+		167717: {12444, 165464},
+	}
+	for set, E := range sizes {
+		scheme, err := NewScheme(set)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if scheme.PublicKeySize() != set || scheme.PrivateKeySize() != E[0] || scheme.SignatureSize() != E[1] {
+			test.Errorf("MEDS-%v: pk %v, sk %v, sig %v bytes, expected %v, %v, %v\n", set,
+				scheme.PublicKeySize(), scheme.PrivateKeySize(), scheme.SignatureSize(), set, E[0], E[1])
+		}
+	}
+}
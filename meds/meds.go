@@ -1,182 +1,238 @@
 package meds
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"meds/matrix"
+	"sync/atomic"
 
 	"golang.org/x/crypto/sha3"
 )
 
-var q, q_bitlen, n, m, k, s, t, w int
-var l_tree_seed, l_sec_seed, l_pub_seed, l_salt, l_digest int
-var l_f_mm, l_f_nn, l_G_i, l_sk, l_pk, l_path, l_sig int
+var (
+	// ErrInvalidSignature is returned when a signature does not verify under the public key
+	ErrInvalidSignature = errors.New("meds: invalid signature")
+	// ErrMalformedSignature is returned when a signature can not be decoded into a valid response
+	ErrMalformedSignature = errors.New("meds: malformed signature")
+	// ErrWrongLength is returned when a key or signature does not have the length of the parameter set
+	ErrWrongLength = errors.New("meds: wrong length")
+	// ErrNonInvertibleResponse is returned when a response matrix mu or nu in a signature is not invertible
+	ErrNonInvertibleResponse = errors.New("meds: non-invertible response")
+	// ErrMalformedKey is returned when a key can not be decoded into its parts
+	ErrMalformedKey = errors.New("meds: malformed key")
+	// ErrKeyMismatch is returned when a key pair is not the one derived from the seed in the secret key
+	ErrKeyMismatch = errors.New("meds: key pair does not match its seed")
+	// ErrKeyDestroyed is returned when signing with a PreparedPrivateKey after Destroy
+	ErrKeyDestroyed = errors.New("meds: secret key has been destroyed")
+	// ErrNoParameterSet is returned by the package level functions before ParameterSetup has succeeded
+	ErrNoParameterSet = errors.New("meds: no parameter set selected")
+	// ErrNoKeyPair is returned by KeyGenFromSeed when no key pair is found for the seed
+	ErrNoKeyPair = errors.New("meds: seed gives no key pair")
+)
+
+// maxKeyGenAttempts bounds the samples of A_i and B_i in KeyGenFromSeed. For very
+// small q, G_0 can be such that Solve never gives invertible A_i and B_i, and the
+// bound is never reached for the built-in sets.
+const maxKeyGenAttempts = 1000
+
+// Scheme holds one MEDS parameter set together with the key, path and
+// signature lengths derived from it. A Scheme is never modified after
+// NewScheme returns it, so it is safe for concurrent use by multiple
+// goroutines. WithWorkers returns a modified copy.
+type Scheme struct {
+	set                                                   int
+	workers                                               int
+	q, q_bitlen, n, m, k, s, t, w                         int
+	l_tree_seed, l_sec_seed, l_pub_seed, l_salt, l_digest int
+	l_f_mm, l_f_nn, l_G_i, l_sk, l_pk, l_path, l_sig      int
+	pools                                                 *pools
+}
+
+// defaultScheme is the parameter set used by the package level KeyGen, Sign
+// and Verify functions. It is selected with ParameterSetup.
+var defaultScheme atomic.Pointer[Scheme]
 
-func ParameterSetup(set int) {
+// NewScheme returns the MEDS parameter set identified by set
+// (1, 9923, 13220, 41711, 69497, 134180 or 167717)
+func NewScheme(set int) (*Scheme, error) {
+	params := Parameters{
+		L_sec_seed: 32,
+		L_pub_seed: 32,
+		L_salt:     32,
+		L_digest:   32,
+	}
 	switch set {
 	case 1:
-		q = 4093
-		q_bitlen = 16
-		n = 3
-		m = 3
-		k = 3
-		s = 4
-		t = 1152
-		w = 14
-		l_tree_seed = 16
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 4093, 3, 3, 3, 4, 1152, 14, 16
 	case 9923:
-		q = 4093
-		q_bitlen = 16
-		n = 14
-		m = 14
-		k = 14
-		s = 4
-		t = 1152
-		w = 14
-		l_tree_seed = 16
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 4093, 14, 14, 14, 4, 1152, 14, 16
 	case 13220:
-		q = 4093
-		q_bitlen = 16
-		n = 14
-		m = 14
-		k = 14
-		s = 5
-		t = 192
-		w = 20
-		l_tree_seed = 16
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 4093, 14, 14, 14, 5, 192, 20, 16
 	case 41711:
-		q = 4093
-		q_bitlen = 16
-		n = 22
-		m = 22
-		k = 22
-		s = 4
-		t = 608
-		w = 26
-		l_tree_seed = 24
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 4093, 22, 22, 22, 4, 608, 26, 24
 	case 69497:
-		q = 4093
-		q_bitlen = 16
-		n = 22
-		m = 22
-		k = 22
-		s = 5
-		t = 160
-		w = 36
-		l_tree_seed = 24
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 4093, 22, 22, 22, 6, 160, 36, 24
 	case 134180:
-		q = 2039
-		q_bitlen = 16
-		n = 30
-		m = 30
-		k = 30
-		s = 5
-		t = 192
-		w = 52
-		l_tree_seed = 32
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 2039, 30, 30, 30, 5, 192, 52, 32
 	case 167717:
-		q = 2039
-		q_bitlen = 16
-		n = 30
-		m = 30
-		k = 30
-		s = 6
-		t = 112
-		w = 66
-		l_tree_seed = 32
-		l_sec_seed = 32
-		l_pub_seed = 32
-		l_salt = 32
-		l_digest = 32
-		l_f_mm = m * m * (q_bitlen / 8)
-		l_f_nn = n * n * (q_bitlen / 8)
-		l_G_i = (((k-2)*(m*n-k) + n) * q_bitlen) / 8
-		l_sk = (s-1)*(l_f_mm+l_f_nn) + l_sec_seed + l_pub_seed
-		l_pk = (s-1)*l_G_i + l_pub_seed
-		l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * l_tree_seed
-		l_sig = l_digest + w*(l_f_mm+l_f_nn) + l_path + l_salt
+		params.Q, params.N, params.M, params.K, params.S, params.T, params.W, params.L_tree_seed = 2039, 30, 30, 30, 6, 112, 66, 32
 	default:
-		fmt.Printf("Parameter selection error\n")
+		return nil, fmt.Errorf("unknown MEDS parameter set %v", set)
+	}
+	return newScheme(set, params), nil
+}
+
+// newScheme derives the key, path and signature lengths of params
+// Precondition: params is valid
+func newScheme(set int, params Parameters) *Scheme {
+	p := &Scheme{
+		set:         set,
+		q:           params.Q,
+		n:           params.N,
+		m:           params.M,
+		k:           params.K,
+		s:           params.S,
+		t:           params.T,
+		w:           params.W,
+		l_tree_seed: params.L_tree_seed,
+		l_sec_seed:  params.L_sec_seed,
+		l_pub_seed:  params.L_pub_seed,
+		l_salt:      params.L_salt,
+		l_digest:    params.L_digest,
+	}
+	// Field elements are packed with ceil(log2 q) bits, and every matrix is rounded up to whole bytes
+	p.q_bitlen = matrix.BitLen(p.q)
+	p.l_f_mm = matrix.CompressedSize(p.m, p.m, p.q)
+	p.l_f_nn = matrix.CompressedSize(p.n, p.n, p.q)
+	p.l_G_i = matrix.CompressedSize(1, (p.k-2)*(p.m*p.n-p.k)+p.n, p.q)
+	p.l_sk = (p.s-1)*(p.l_f_mm+p.l_f_nn) + p.l_sec_seed + p.l_pub_seed
+	p.l_pk = (p.s-1)*p.l_G_i + p.l_pub_seed
+	p.l_path = (int(math.Pow(2, math.Ceil(math.Log2(float64(p.w))))) + p.w*(int(math.Ceil(math.Log2(float64(p.t))))-int(math.Ceil(math.Log2(float64(p.w))))-1)) * p.l_tree_seed
+	p.l_sig = p.l_digest + p.w*(p.l_f_mm+p.l_f_nn) + p.l_path + p.l_salt
+	p.pools = newPools(p)
+	return p
+}
+
+// ParameterSets returns the identifiers of the built-in parameter sets accepted by NewScheme
+func ParameterSets() []int {
+	return []int{1, 9923, 13220, 41711, 69497, 134180, 167717}
+}
+
+// ParameterSet returns the identifier of the parameter set, e.g. 9923 for MEDS-9923
+func (p *Scheme) ParameterSet() int {
+	return p.set
+}
+
+// PublicKeySize returns the length in bytes of a public key
+func (p *Scheme) PublicKeySize() int {
+	return p.l_pk
+}
+
+// PrivateKeySize returns the length in bytes of a secret key
+func (p *Scheme) PrivateKeySize() int {
+	return p.l_sk
+}
+
+// CompactPrivateKeySize returns the length in bytes of a compact secret key,
+// which only holds the secret seed delta
+func (p *Scheme) CompactPrivateKeySize() int {
+	return p.l_sec_seed
+}
+
+// SignatureSize returns the length in bytes of a signature, excluding the message
+func (p *Scheme) SignatureSize() int {
+	return p.l_sig
+}
+
+// ParameterSetup selects the parameter set used by the package level KeyGen,
+// Sign and Verify functions. New code should use NewScheme instead.
+func ParameterSetup(set int) error {
+	p, err := NewScheme(set)
+	if err != nil {
+		return err
+	}
+	defaultScheme.Store(p)
+	return nil
+}
+
+// loadDefaultScheme returns the parameter set selected with ParameterSetup
+// Returns: *Scheme, or ErrNoParameterSet if none has been selected
+func loadDefaultScheme() (*Scheme, error) {
+	p := defaultScheme.Load()
+	if p == nil {
+		return nil, ErrNoParameterSet
+	}
+	return p, nil
+}
+
+// KeyGen generates a key pair using the parameter set selected with ParameterSetup
+// Returns: (pk, sk, error), or ErrNoParameterSet before ParameterSetup has been called
+func KeyGen() ([]byte, []byte, error) {
+	p, err := loadDefaultScheme()
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.KeyGen(nil)
+}
+
+// Sign signs msg using the parameter set selected with ParameterSetup
+// Returns: The signature followed by msg
+func Sign(sk, msg []byte) ([]byte, error) {
+	p, err := loadDefaultScheme()
+	if err != nil {
+		return nil, err
 	}
+	return p.Sign(nil, sk, msg)
 }
 
-func KeyGen() ([]byte, []byte) {
-	delta := Randombytes(l_sec_seed)
-	sigma_G_0 := make([]byte, l_pub_seed)
-	sigma := make([]byte, l_sec_seed)
+// Verify verifies msg_s using the parameter set selected with ParameterSetup
+// Returns: The message if the signature is valid, otherwise an error
+func Verify(pk, msg_s []byte) ([]byte, error) {
+	p, err := loadDefaultScheme()
+	if err != nil {
+		return nil, err
+	}
+	return p.Verify(pk, msg_s)
+}
+
+// KeyGen generates a MEDS key pair with the secret seed read from rng.
+// If rng is nil, crypto/rand.Reader is used. A new seed is read if the
+// seed gives no key pair.
+// Returns: (pk, sk, error)
+func (p *Scheme) KeyGen(rng io.Reader) ([]byte, []byte, error) {
+	for {
+		delta, err := Randombytes(rng, p.l_sec_seed)
+		if err != nil {
+			return nil, nil, err
+		}
+		pk, sk, err := p.KeyGenFromSeed(delta)
+		clear(delta)
+		if !errors.Is(err, ErrNoKeyPair) {
+			return pk, sk, err
+		}
+	}
+}
+
+// KeyGenFromSeed deterministically derives a MEDS key pair from the secret seed delta.
+// The same delta always gives the same key pair, and delta is stored at the start of sk.
+// Returns: (pk, sk, error), where the error is ErrNoKeyPair if the seed gives no key pair
+func (p *Scheme) KeyGenFromSeed(delta []byte) ([]byte, []byte, error) {
+	if len(delta) != p.l_sec_seed {
+		return nil, nil, fmt.Errorf("%w: seed is %v bytes, expected %v", ErrWrongLength, len(delta), p.l_sec_seed)
+	}
+	sigma_G_0 := make([]byte, p.l_pub_seed)
+	sigma := make([]byte, p.l_sec_seed)
 	xof := sha3.NewShake256()
 	xof.Write(delta)
 	xof.Read(sigma_G_0)
 	xof.Read(sigma)
-	G_0 := ExpandSystMat(sigma_G_0, q, k, m, n)
-	sk := make([]byte, l_sk)
-	pk := make([]byte, l_pk)
+	G_0 := ExpandSystMat(sigma_G_0, p.q, p.k, p.m, p.n)
+	sk := make([]byte, p.l_sk)
+	pk := make([]byte, p.l_pk)
 
 	sk_idx := 0
 	pk_idx := 0
@@ -184,38 +240,90 @@ func KeyGen() ([]byte, []byte) {
 	addToKey(sk, sigma_G_0, &sk_idx)
 	addToKey(pk, sigma_G_0, &pk_idx)
 
-	offset := n * m * Bytelen(q)
 	sk_A_idx := sk_idx
-	sk_B_idx := sk_idx + (s-1)*offset
-	I := matrix.Identity(m, q)
-	for i := 1; i < s; i++ {
+	sk_B_idx := sk_idx + (p.s-1)*p.l_f_mm
+	I := matrix.Identity(p.m, p.q)
+	for i := 1; i < p.s; i++ {
 		var G *matrix.Matrix = nil
 		var A_inv *matrix.Matrix
 		var A, B_inv *matrix.Matrix = nil, nil
+		attempts := 0
 		for G == nil {
+			// Resample A and B if G has no systematic form
+			A, B_inv = nil, nil
 			for (A == nil && B_inv == nil) || !Invertable(A, I) || !Invertable(B_inv, I) {
+				attempts++
+				if attempts > maxKeyGenAttempts {
+					clear(sk)
+					return nil, nil, fmt.Errorf("%w: no invertible A_%v and B_%v in %v attempts", ErrNoKeyPair, i, i, maxKeyGenAttempts)
+				}
 				xof := sha3.NewShake256()
 				xof.Write(sigma)
-				sigma_a := make([]byte, l_sec_seed)
-				sigma_T := make([]byte, l_sec_seed)
+				sigma_a := make([]byte, p.l_sec_seed)
+				sigma_T := make([]byte, p.l_sec_seed)
 				xof.Read(sigma_a)
 				xof.Read(sigma_T)
 				xof.Read(sigma)
-				T_i := ExpandInvMat(sigma_T, q, k)
-				a_mm := ExpandFqs(sigma_a, 1, q)[0]
+				T_i := ExpandInvMat(sigma_T, p.q, p.k)
+				a_mm := ExpandFqs(sigma_a, 1, p.q)[0]
 				G_0_prime := T_i.Mul(G_0)
-				A, B_inv = Solve(G_0_prime, a_mm, m, n)
+				A, B_inv = Solve(G_0_prime, a_mm, p.m, p.n)
 			}
 			A_inv = Inverse(A)
 			B := Inverse(B_inv)
 			G = Pi(A, G_0, B)
 			G = SF(G)
 		}
-		addToKey(pk, CompressG(G), &pk_idx)
+		addToKey(pk, CompressG(G, p.m, p.n, p.k), &pk_idx)
 		addToKey(sk, A_inv.Compress(), &sk_A_idx)
 		addToKey(sk, B_inv.Compress(), &sk_B_idx)
 	}
-	return pk, sk
+	return pk, sk, nil
+}
+
+// CheckKeyPair regenerates the key pair from the seed stored in sk and compares it to (pk, sk).
+// sk may be an expanded or a compact secret key.
+// Returns: nil if the keys match, otherwise an error
+func (p *Scheme) CheckKeyPair(pk, sk []byte) error {
+	if len(sk) != p.l_sk && len(sk) != p.l_sec_seed {
+		return fmt.Errorf("%w: secret key is %v bytes, expected %v or %v", ErrWrongLength, len(sk), p.l_sk, p.l_sec_seed)
+	}
+	pk_prime, sk_prime, err := p.KeyGenFromSeed(sk[:p.l_sec_seed])
+	if err != nil {
+		return err
+	}
+	defer clear(sk_prime)
+	if !bytes.Equal(pk, pk_prime) || (len(sk) == p.l_sk && subtle.ConstantTimeCompare(sk, sk_prime) != 1) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// CompactPrivateKey converts the secret key sk to the compact format,
+// which only holds the secret seed delta
+// Returns: The compact secret key of length CompactPrivateKeySize()
+func (p *Scheme) CompactPrivateKey(sk []byte) ([]byte, error) {
+	if len(sk) != p.l_sk && len(sk) != p.l_sec_seed {
+		return nil, fmt.Errorf("%w: secret key is %v bytes, expected %v or %v", ErrWrongLength, len(sk), p.l_sk, p.l_sec_seed)
+	}
+	return bytes.Clone(sk[:p.l_sec_seed]), nil
+}
+
+// ExpandPrivateKey converts the secret key sk to the expanded format of KeyGen
+// by re-deriving sigma_G_0, A_inv and B_inv from delta
+// Returns: The expanded secret key of length PrivateKeySize()
+func (p *Scheme) ExpandPrivateKey(sk []byte) ([]byte, error) {
+	if len(sk) != p.l_sk && len(sk) != p.l_sec_seed {
+		return nil, fmt.Errorf("%w: secret key is %v bytes, expected %v or %v", ErrWrongLength, len(sk), p.l_sk, p.l_sec_seed)
+	}
+	if len(sk) == p.l_sk {
+		return bytes.Clone(sk), nil
+	}
+	_, sk_prime, err := p.KeyGenFromSeed(sk)
+	if err != nil {
+		return nil, err
+	}
+	return sk_prime, nil
 }
 
 func addToKey(key, bs []byte, idx *int) {
@@ -225,199 +333,244 @@ func addToKey(key, bs []byte, idx *int) {
 	(*idx) += len(bs)
 }
 
-func Sign(sk, msg []byte) ([]byte, error) {
-	f_sk := l_sec_seed
-	sigma_G_0 := sk[f_sk : f_sk+l_pub_seed]
-	f_sk += l_pub_seed
-	G_0 := ExpandSystMat(sigma_G_0, q, k, m, n)
-	A_inv := make([]*matrix.Matrix, s-1)
-	B_inv := make([]*matrix.Matrix, s-1)
-	for i := 0; i < s-1; i++ {
-		A_inv[i] = matrix.Decompress(sk[f_sk:f_sk+l_f_mm], m, m, q)
-		f_sk += l_f_mm
-	}
-	for i := 0; i < s-1; i++ {
-		B_inv[i] = matrix.Decompress(sk[f_sk:f_sk+l_f_nn], n, n, q)
-		f_sk += l_f_nn
-	}
-	delta := Randombytes(l_sec_seed)
+// Sign signs msg with the secret key sk using randomness read from rng.
+// If rng is nil, crypto/rand.Reader is used. sk may be compact, in which case
+// A_inv and B_inv are re-derived on every call; use NewPrivateKeyFromSeed to keep them.
+// Returns: The signature followed by msg
+func (p *Scheme) Sign(rng io.Reader, sk, msg []byte) ([]byte, error) {
+	sig, err := p.SignDetached(rng, sk, msg)
+	if err != nil {
+		return nil, err
+	}
+	return p.JoinSignedMessage(sig, msg), nil
+}
+
+// SignDetached signs msg with the secret key sk using randomness read from rng.
+// If rng is nil, crypto/rand.Reader is used. sk may be expanded or compact.
+// Returns: The signature of length SignatureSize() without the message
+func (p *Scheme) SignDetached(rng io.Reader, sk, msg []byte) ([]byte, error) {
+	priv, err := p.parsePrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.signDetached(rng, priv, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	return signature.MarshalBinary()
+}
+
+// SignReader signs the message read from r until EOF with the secret key sk.
+// The message is streamed into the challenge hash and never held in memory.
+// If rng is nil, crypto/rand.Reader is used. sk may be expanded or compact.
+// Returns: The same detached signature as SignDetached on the whole message
+func (p *Scheme) SignReader(rng io.Reader, sk []byte, r io.Reader) ([]byte, error) {
+	priv, err := p.parsePrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.signDetached(rng, priv, r)
+	if err != nil {
+		return nil, err
+	}
+	return signature.MarshalBinary()
+}
+
+func (p *Scheme) signDetached(rng io.Reader, priv *PrivateKey, msg io.Reader) (*Signature, error) {
+	return p.signExpanded(rng, priv, ExpandSystMat(priv.Sigma_G_0, p.q, p.k, p.m, p.n), msg)
+}
+
+// signExpanded is signDetached with G_0 already expanded from priv.Sigma_G_0
+func (p *Scheme) signExpanded(rng io.Reader, priv *PrivateKey, G_0 *matrix.Matrix, msg io.Reader) (*Signature, error) {
+	r := p.getRounds()
+	defer p.putRounds(r)
+	err := readRandom(rng, r.delta)
+	if err != nil {
+		return nil, err
+	}
 	xof := sha3.NewShake256()
-	xof.Write(delta)
-	rho := make([]byte, l_tree_seed)
-	alpha := make([]byte, l_salt)
-	xof.Read(rho)
+	xof.Write(r.delta)
+	alpha := make([]byte, p.l_salt)
+	xof.Read(r.rho)
 	xof.Read(alpha)
-	seeds, err := SeedTree(rho, alpha, t)
+	r.tree.Generate(r.rho, alpha)
+	l_commitment := p.commitmentSize()
+	err = p.forEachRound(p.t, func(i int) error {
+		ws := p.getWorkspace()
+		defer p.putWorkspace(ws)
+		p.commitment(ws, G_0, alpha, r.tree.Leaf(i), i, r.G[i*l_commitment:(i+1)*l_commitment], r.sigma[i*2*p.l_pub_seed:(i+1)*2*p.l_pub_seed])
+		return nil
+	})
 	if err != nil {
-		return []byte{}, err
-	}
-	G_tilde := make([]*matrix.Matrix, t)
-	A_tilde := make([]*matrix.Matrix, t)
-	B_tilde := make([]*matrix.Matrix, t)
-	sigma_prime := make([]byte, l_salt+l_tree_seed+4)
-	for i := 0; i < t; i++ {
-		for G_tilde[i] == nil {
-			idx := 0
-			for j := 0; j < l_salt; j++ {
-				sigma_prime[idx] = alpha[j]
-				idx++
-			}
-			for j := 0; j < l_tree_seed; j++ {
-				sigma_prime[idx] = seeds[i][j]
-				idx++
-			}
-			x, err := ToBytes(int32(math.Pow(2, float64(1+int(math.Ceil(math.Log2(float64(t)))))))+int32(i), 4)
-			if err != nil {
-				return []byte{}, err
-			}
-			for j := 0; j < 4; j++ {
-				sigma_prime[idx] = x[j]
-				idx++
-			}
-			sigma_A_tilde := make([]byte, l_pub_seed)
-			sigma_B_tilde := make([]byte, l_pub_seed)
-			xof = sha3.NewShake256()
-			xof.Write(sigma_prime)
-			xof.Read(sigma_A_tilde)
-			xof.Read(sigma_B_tilde)
-			xof.Read(seeds[i])
-			A_tilde[i] = ExpandInvMat(sigma_A_tilde, q, m)
-			B_tilde[i] = ExpandInvMat(sigma_B_tilde, q, n)
-			G_tilde[i] = Pi(A_tilde[i], G_0, B_tilde[i])
-			G_tilde[i] = SF(G_tilde[i])
-		}
+		return nil, err
 	}
 	H := sha3.NewShake256()
-	for i := 0; i < t; i++ {
-		H.Write(G_tilde[i].Submatrix(0, G_tilde[i].M, k, m*n).Compress())
+	H.Write(r.G)
+	_, err = io.Copy(H, msg)
+	if err != nil {
+		return nil, fmt.Errorf("meds: reading message: %w", err)
 	}
-	H.Write(msg)
-	d := make([]byte, l_digest)
+	d := make([]byte, p.l_digest)
 	H.Read(d)
 
-	h := ParseHash(s, t, w, d)
-	msg_s := make([]byte, l_sig+len(msg))
-	idx := 0
-	for i := 0; i < t; i++ {
+	h := ParseHash(p.s, p.t, p.w, d)
+	signature := &Signature{
+		scheme: p,
+		Mu:     matrix.NewBatch(p.w, p.m, p.m, p.q),
+		Nu:     matrix.NewBatch(p.w, p.n, p.n, p.q),
+		Digest: d,
+		Salt:   alpha,
+	}
+	ws := p.getWorkspace()
+	defer p.putWorkspace(ws)
+	j := 0
+	for i := 0; i < p.t; i++ {
 		if h[i] > 0 {
-			mu := A_tilde[i].Mul(A_inv[h[i]-1])
-			nu := B_inv[h[i]-1].Mul(B_tilde[i])
-			for _, b := range mu.Compress() {
-				msg_s[idx] = b
-				idx++
-			}
-			for _, b := range nu.Compress() {
-				msg_s[idx] = b
-				idx++
-			}
+			// Only the seeds of A_tilde and B_tilde are kept for every round
+			sigma := r.sigma[i*2*p.l_pub_seed : (i+1)*2*p.l_pub_seed]
+			expandInvMat(ws.A_tilde, ws.A_scratch, ws.shake, sigma[:p.l_pub_seed], ws.buf)
+			expandInvMat(ws.B_tilde, ws.B_scratch, ws.shake, sigma[p.l_pub_seed:], ws.buf)
+			ws.A_tilde.MulInto(priv.A_inv[h[i]-1], signature.Mu[j])
+			priv.B_inv[h[i]-1].MulInto(ws.B_tilde, signature.Nu[j])
+			j++
 		}
 	}
+	signature.Path = make([]byte, p.l_path)
+	r.tree.ToPath(h, signature.Path)
+
+	return signature, nil
+}
 
-	p := SeedTreeToPath(w, t, h, rho, alpha)
-	for i := 0; i < len(p); i++ {
-		msg_s[idx] = p[i]
-		idx++
+// Verify verifies the signed message msg_s under the public key pk
+// Returns: The message if the signature is valid, otherwise an error
+func (p *Scheme) Verify(pk, msg_s []byte) ([]byte, error) {
+	sig, msg, err := p.SplitSignedMessage(msg_s)
+	if err != nil {
+		return nil, err
 	}
-	for i := 0; i < len(d); i++ {
-		msg_s[idx] = d[i]
-		idx++
+	err = p.VerifyDetached(pk, msg, sig)
+	if err != nil {
+		return nil, err
 	}
-	for i := 0; i < len(alpha); i++ {
-		msg_s[idx] = alpha[i]
-		idx++
+	return msg, nil
+}
+
+// VerifyDetached verifies the signature sig on msg under the public key pk
+// Returns: nil if the signature is valid, otherwise an error
+func (p *Scheme) VerifyDetached(pk, msg, sig []byte) error {
+	pub, err := p.parsePublicKey(pk)
+	if err != nil {
+		return err
 	}
-	for i := 0; i < len(msg); i++ {
-		msg_s[idx] = msg[i]
-		idx++
+	signature, err := p.parseSignature(sig)
+	if err != nil {
+		return err
 	}
-
-	return msg_s, nil
+	return p.verifyDetached(pub, bytes.NewReader(msg), signature)
 }
 
-func Verify(pk, msg_s []byte) []byte {
-	sigma_G_0 := pk[:l_pub_seed]
-	G_0 := ExpandSystMat(sigma_G_0, q, k, m, n)
-	f_pk := l_pub_seed
-	G := make([]*matrix.Matrix, s-1)
-	for i := 0; i < s-1; i++ {
-		G[i] = DecompressG(pk[f_pk:f_pk+l_G_i], q, m, n, k)
-		f_pk += l_G_i
+// VerifyReader verifies the detached signature sig on the message read from r until EOF.
+// The message is streamed into the challenge hash and never held in memory.
+// Returns: nil if the signature is valid, otherwise an error
+func (p *Scheme) VerifyReader(pk []byte, r io.Reader, sig []byte) error {
+	pub, err := p.parsePublicKey(pk)
+	if err != nil {
+		return err
 	}
+	signature, err := p.parseSignature(sig)
+	if err != nil {
+		return err
+	}
+	return p.verifyDetached(pub, r, signature)
+}
+
+func (p *Scheme) verifyDetached(pub *PublicKey, msg io.Reader, signature *Signature) error {
+	return p.verifyExpanded(pub, ExpandSystMat(pub.Sigma_G_0, p.q, p.k, p.m, p.n), msg, signature)
+}
 
-	p := msg_s[l_sig-l_digest-l_salt-l_path : l_sig-l_digest-l_salt]
-	d := msg_s[l_sig-l_digest-l_salt : l_sig-l_salt]
-	alpha := msg_s[l_sig-l_salt : l_sig]
-	msg := msg_s[l_sig:]
-	h := ParseHash(s, t, w, d)
-	seeds := PathToSeedTree(h, p, alpha, l_tree_seed)
-	f_msg_s := 0
-	I := matrix.Identity(m, q)
-	G_hat := make([]*matrix.Matrix, t)
-	for i := 0; i < t; i++ {
+// verifyExpanded is verifyDetached with G_0 already expanded from pub.Sigma_G_0
+func (p *Scheme) verifyExpanded(pub *PublicKey, G_0 *matrix.Matrix, msg io.Reader, signature *Signature) error {
+	d := signature.Digest
+	alpha := signature.Salt
+	h := ParseHash(p.s, p.t, p.w, d)
+	r := p.getRounds()
+	defer p.putRounds(r)
+	err := r.tree.FromPath(h, signature.Path, alpha)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedSignature, err)
+	}
+	// The challenged rounds come first in order, since they are cheap
+	// and are the only ones that can fail on a malformed signature
+	order := r.order[:0]
+	response := r.response
+	for i := 0; i < p.t; i++ {
 		if h[i] > 0 {
-			mu := matrix.Decompress(msg_s[f_msg_s:f_msg_s+l_f_mm], m, m, q)
-			nu := matrix.Decompress(msg_s[f_msg_s+l_f_mm:f_msg_s+l_f_mm+l_f_nn], n, n, q)
-			f_msg_s += l_f_mm + l_f_nn
-			if !Invertable(mu, I) || !Invertable(nu, I) {
-				fmt.Print("Mu or Nu not invertable\n")
-				return nil
-			}
-			G_hat[i] = Pi(mu, G[h[i]-1], nu)
-			err := SF_on_submatrix(G_hat[i], 0, 0, G_hat[i].M, G_hat[i].N)
-			if err != nil {
-				fmt.Print("SF failed on G_hat\n")
-				return nil
-			}
-		} else {
-			for G_hat[i] == nil {
-				sigma_prime := make([]byte, l_salt+l_tree_seed+4)
-				sigma_A := make([]byte, l_pub_seed)
-				sigma_B := make([]byte, l_pub_seed)
-				x, err := ToBytes(int32(math.Pow(2, float64(1+int(math.Ceil(math.Log2(float64(t)))))))+int32(i), 4)
-				if err != nil {
-					fmt.Print("Failed getting byte value\n")
-					return nil
-				}
-				idx := 0
-				for j := 0; j < l_salt; j++ {
-					sigma_prime[idx] = alpha[j]
-					idx++
-				}
-				for j := 0; j < l_tree_seed; j++ {
-					sigma_prime[idx] = seeds[i][j]
-					idx++
-				}
-				for j := 0; j < 4; j++ {
-					sigma_prime[idx] = x[j]
-					idx++
-				}
-				xof := sha3.NewShake256()
-				xof.Write(sigma_prime)
-				xof.Read(sigma_A)
-				xof.Read(sigma_B)
-				xof.Read(seeds[i])
-				A_hat := ExpandInvMat(sigma_A, q, m)
-				B_hat := ExpandInvMat(sigma_B, q, n)
-				G_hat[i] = Pi(A_hat, G_0, B_hat)
-				G_hat[i] = SF(G_hat[i])
-			}
+			response[i] = len(order)
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < p.t; i++ {
+		if h[i] == 0 {
+			order = append(order, i)
+		}
+	}
+	l_commitment := p.commitmentSize()
+	err = p.forEachRound(p.t, func(j int) error {
+		i := order[j]
+		ws := p.getWorkspace()
+		defer p.putWorkspace(ws)
+		G_hat := r.G[i*l_commitment : (i+1)*l_commitment]
+		if h[i] == 0 {
+			p.commitment(ws, G_0, alpha, r.tree.Leaf(i), i, G_hat, nil)
+			return nil
+		}
+		mu := signature.Mu[response[i]]
+		nu := signature.Nu[response[i]]
+		if !invertible(mu, ws.A_scratch) || !invertible(nu, ws.B_scratch) {
+			return fmt.Errorf("%w: round %v", ErrNonInvertibleResponse, i)
+		}
+		piInto(ws.G_tilde, mu, pub.G[h[i]-1], nu, ws.P, ws.AP)
+		err := SF_on_submatrix(ws.G_tilde, 0, 0, ws.G_tilde.M, ws.G_tilde.N)
+		if err != nil {
+			return fmt.Errorf("%w: round %v: %v", ErrMalformedSignature, i, err)
 		}
+		ws.compressG_sub(G_hat)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	d_prime := make([]byte, l_digest)
+	d_prime := r.d
 	H := sha3.NewShake256()
-	for i := 0; i < t; i++ {
-		H.Write(G_hat[i].Submatrix(0, G_hat[i].M, k, m*n).Compress())
+	H.Write(r.G)
+	_, err = io.Copy(H, msg)
+	if err != nil {
+		return fmt.Errorf("meds: reading message: %w", err)
 	}
-	H.Write(msg)
 	H.Read(d_prime)
 	equal := true
-	for i := 0; equal && i < l_digest; i++ {
+	for i := 0; equal && i < p.l_digest; i++ {
 		equal = d[i] == d_prime[i]
 	}
-	if equal {
-		return msg
+	if !equal {
+		return ErrInvalidSignature
 	}
-
-	fmt.Print("Signature not valid\n")
 	return nil
 }
+
+// SplitSignedMessage splits a signed message as returned by Sign into the signature and the message
+// Returns: (sig, msg, error)
+func (p *Scheme) SplitSignedMessage(msg_s []byte) ([]byte, []byte, error) {
+	if len(msg_s) < p.l_sig {
+		return nil, nil, fmt.Errorf("%w: signed message is %v bytes, expected at least %v", ErrWrongLength, len(msg_s), p.l_sig)
+	}
+	return msg_s[:p.l_sig], msg_s[p.l_sig:], nil
+}
+
+// JoinSignedMessage joins a detached signature and its message into the signed message format of Sign
+// Returns: sig || msg
+func (p *Scheme) JoinSignedMessage(sig, msg []byte) []byte {
+	msg_s := make([]byte, 0, len(sig)+len(msg))
+	msg_s = append(msg_s, sig...)
+	return append(msg_s, msg...)
+}
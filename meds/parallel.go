@@ -0,0 +1,92 @@
+package meds
+
+import (
+	"encoding/binary"
+	"math"
+	"meds/matrix"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// WithWorkers returns a copy of the scheme that computes the t rounds of
+// Sign and Verify on n goroutines. If n <= 0, runtime.GOMAXPROCS(0) is used,
+// which is also the default of NewScheme. The output does not depend on n.
+func (p *Scheme) WithWorkers(n int) *Scheme {
+	p_prime := *p
+	p_prime.workers = n
+	return &p_prime
+}
+
+// Workers returns the number of goroutines used for the rounds of Sign and Verify
+func (p *Scheme) Workers() int {
+	if p.workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return p.workers
+}
+
+// forEachRound calls round(i) for every i in [0, t) on a pool of p.Workers() goroutines.
+// Once a round fails no new rounds are started.
+// Returns: nil, or the error of the lowest failed round
+func (p *Scheme) forEachRound(t int, round func(i int) error) error {
+	workers := min(p.Workers(), t)
+	errs := make([]error, t)
+	var next atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= t {
+					return
+				}
+				errs[i] = round(i)
+				if errs[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitment expands the seed of round i into A_tilde and B_tilde and computes
+// G_tilde = SF(Pi(A_tilde, G_0, B_tilde)) in ws. The seed is re-expanded until
+// G_tilde has a systematic form. The last mn-k columns of G_tilde are
+// compressed into out, and sigma_A_tilde || sigma_B_tilde is copied to sigma
+// unless it is nil.
+func (p *Scheme) commitment(ws *workspace, G_0 *matrix.Matrix, alpha, seed []byte, i int, out, sigma []byte) {
+	binary.LittleEndian.PutUint32(ws.x, uint32(int32(math.Pow(2, float64(1+int(math.Ceil(math.Log2(float64(p.t))))))))+uint32(i))
+	copy(ws.seed, seed)
+	for {
+		ws.sigma_prime = append(ws.sigma_prime[:0], alpha...)
+		ws.sigma_prime = append(ws.sigma_prime, ws.seed...)
+		ws.sigma_prime = append(ws.sigma_prime, ws.x...)
+		ws.shake.Reset()
+		ws.shake.Write(ws.sigma_prime)
+		ws.shake.Read(ws.sigma_A_tilde)
+		ws.shake.Read(ws.sigma_B_tilde)
+		ws.shake.Read(ws.seed)
+		expandInvMat(ws.A_tilde, ws.A_scratch, ws.shake, ws.sigma_A_tilde, ws.buf)
+		expandInvMat(ws.B_tilde, ws.B_scratch, ws.shake, ws.sigma_B_tilde, ws.buf)
+		piInto(ws.G_tilde, ws.A_tilde, G_0, ws.B_tilde, ws.P, ws.AP)
+		if SF_on_submatrix(ws.G_tilde, 0, 0, ws.G_tilde.M, ws.G_tilde.N) == nil {
+			break
+		}
+	}
+	ws.compressG_sub(out)
+	if sigma != nil {
+		copy(sigma, ws.sigma_A_tilde)
+		copy(sigma[len(ws.sigma_A_tilde):], ws.sigma_B_tilde)
+	}
+}
@@ -0,0 +1,72 @@
+package meds
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEachRound(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	for _, workers := range []int{1, 3, 200} {
+		done := make([]atomic.Int32, 100)
+		err := scheme.WithWorkers(workers).forEachRound(len(done), func(i int) error {
+			done[i].Add(1)
+			return nil
+		})
+		if err != nil {
+			test.Fatal(err)
+		}
+		for i := range done {
+			if done[i].Load() != 1 {
+				test.Errorf("%v workers: round %v ran %v times\n", workers, i, done[i].Load())
+			}
+		}
+	}
+
+	var calls atomic.Int32
+	err := scheme.WithWorkers(1).forEachRound(100, func(i int) error {
+		calls.Add(1)
+		if i == 3 {
+			return fmt.Errorf("round %v", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "round 3" {
+		test.Errorf("Unexpected error: %v\n", err)
+	}
+	if calls.Load() != 4 {
+		test.Errorf("%v rounds ran after a failed round\n", calls.Load()-4)
+	}
+}
+
+func TestVerifyWorkers(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig, err := scheme.SignDetached(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	// mu_0 = 0 is not invertible
+	bad := bytes.Clone(sig)
+	for i := 0; i < scheme.l_f_mm; i++ {
+		bad[i] = 0
+	}
+	for _, workers := range []int{1, 2, 7, 5000} {
+		parallel := scheme.WithWorkers(workers)
+		if err := parallel.VerifyDetached(pk, msg, sig); err != nil {
+			test.Errorf("%v workers: %v\n", workers, err)
+		}
+		if err := parallel.VerifyDetached(pk, []byte("Another message"), sig); !errors.Is(err, ErrInvalidSignature) {
+			test.Errorf("%v workers: signature valid for another message: %v\n", workers, err)
+		}
+		if err := parallel.VerifyDetached(pk, msg, bad); !errors.Is(err, ErrNonInvertibleResponse) {
+			test.Errorf("%v workers: non-invertible response accepted: %v\n", workers, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package meds
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrInvalidParameters is returned for a parameter definition that the scheme can not be instantiated with
+var ErrInvalidParameters = errors.New("meds: invalid parameters")
+
+// Parameters are the free parameters of a MEDS parameter set. All other
+// lengths are derived from them by NewSchemeFromParameters. The field names
+// in a JSON or TOML parameter file are those of the specification, e.g.
+//
+//	q = 4093
+//	m = 14
+//	n = 14
+//	k = 14
+//	s = 4
+//	t = 1152
+//	w = 14
+//	l_tree_seed = 16
+//
+// The seed, salt and digest lengths other than l_tree_seed default to 32 bytes.
+type Parameters struct {
+	// Q is the prime size of the field F_q
+	Q int `json:"q" toml:"q"`
+	// M, N and K are the dimensions of the k-dimensional matrix codes in F_q^{m x n}
+	M int `json:"m" toml:"m"`
+	N int `json:"n" toml:"n"`
+	K int `json:"k" toml:"k"`
+	// S is the number of public codes G_0, ..., G_{s-1}
+	S int `json:"s" toml:"s"`
+	// T is the number of rounds and W the number of rounds with a non-zero challenge
+	T int `json:"t" toml:"t"`
+	W int `json:"w" toml:"w"`
+
+	L_tree_seed int `json:"l_tree_seed" toml:"l_tree_seed"`
+	L_sec_seed  int `json:"l_sec_seed" toml:"l_sec_seed"`
+	L_pub_seed  int `json:"l_pub_seed" toml:"l_pub_seed"`
+	L_salt      int `json:"l_salt" toml:"l_salt"`
+	L_digest    int `json:"l_digest" toml:"l_digest"`
+}
+
+// Parameters returns the free parameters of the parameter set
+func (p *Scheme) Parameters() Parameters {
+	return Parameters{
+		Q:           p.q,
+		M:           p.m,
+		N:           p.n,
+		K:           p.k,
+		S:           p.s,
+		T:           p.t,
+		W:           p.w,
+		L_tree_seed: p.l_tree_seed,
+		L_sec_seed:  p.l_sec_seed,
+		L_pub_seed:  p.l_pub_seed,
+		L_salt:      p.l_salt,
+		L_digest:    p.l_digest,
+	}
+}
+
+// Validate checks that the parameters are consistent and supported by this implementation
+// Returns: nil, or an error wrapping ErrInvalidParameters that names the first violated condition
+func (params Parameters) Validate() error {
+	invalid := func(format string, a ...any) error {
+		return fmt.Errorf("%w: "+format, append([]any{ErrInvalidParameters}, a...)...)
+	}
+	switch {
+	// Field elements are stored as uint16
+	case params.Q < 2 || params.Q >= 1<<16:
+		return invalid("q = %v is not in [2, 2^16)", params.Q)
+	case !isPrime(params.Q):
+		return invalid("q = %v is not prime", params.Q)
+	// Solve derives the key pairs for square A and B and needs at least three rows
+	case params.M != params.N:
+		return invalid("m = %v and n = %v differ, which is not supported", params.M, params.N)
+	case params.M < 3:
+		return invalid("m = n = %v is less than 3", params.M)
+	// The first two rows of G_i are fixed by Solve, with their ones at columns
+	// i(n+1) and i(n+1)+1. They must lie outside the k x k identity part.
+	case params.K < 2 || params.K > params.N+1:
+		return invalid("k = %v is not in [2, n+1 = %v]", params.K, params.N+1)
+	// Challenges are encoded in a byte
+	case params.S < 2 || params.S > 256:
+		return invalid("s = %v is not in [2, 256]", params.S)
+	case params.W < 1 || params.W > params.T:
+		return invalid("w = %v is not in [1, t = %v]", params.W, params.T)
+	// Seed tree node addresses are 16 bits, and the tree has 2^ceil(log2 t) leaves
+	case params.T > 1<<15:
+		return invalid("t = %v exceeds the 16 bit tree addresses, at most 2^15", params.T)
+	case params.L_tree_seed < 1 || params.L_sec_seed < 1 || params.L_pub_seed < 1 || params.L_salt < 1 || params.L_digest < 1:
+		return invalid("seed, salt and digest lengths must be positive")
+	}
+	return nil
+}
+
+// isPrime reports whether q is prime by trial division
+func isPrime(q int) bool {
+	if q < 2 {
+		return false
+	}
+	for d := 2; d*d <= q; d++ {
+		if q%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewSchemeFromParameters returns a user-defined parameter set. Its
+// identifier is the public key size, as for the built-in MEDS-<pk size> sets.
+// Returns: *Scheme, or an error wrapping ErrInvalidParameters
+func NewSchemeFromParameters(params Parameters) (*Scheme, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, err
+	}
+	p := newScheme(0, params)
+	p.set = p.l_pk
+	return p, nil
+}
+
+// ParseParameters decodes a parameter definition in format "json" or "toml".
+// Unknown fields are rejected, and omitted lengths other than l_tree_seed
+// default to 32 bytes.
+// Returns: Parameters, which are not validated, or an error
+func ParseParameters(data []byte, format string) (Parameters, error) {
+	params := Parameters{
+		L_sec_seed: 32,
+		L_pub_seed: 32,
+		L_salt:     32,
+		L_digest:   32,
+	}
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&params)
+		if err != nil {
+			return Parameters{}, fmt.Errorf("meds: parsing parameters: %w", err)
+		}
+	case "toml":
+		meta, err := toml.Decode(string(data), &params)
+		if err != nil {
+			return Parameters{}, fmt.Errorf("meds: parsing parameters: %w", err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return Parameters{}, fmt.Errorf("meds: parsing parameters: unknown field %q", undecoded[0].String())
+		}
+	default:
+		return Parameters{}, fmt.Errorf("meds: unknown parameter format %q", format)
+	}
+	return params, nil
+}
+
+// LoadParameters reads a parameter definition from the file at path. The
+// format is chosen by the extension, .json or .toml.
+// Returns: *Scheme for the parameters, or an error
+func LoadParameters(path string) (*Scheme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	params, err := ParseParameters(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	scheme, err := NewSchemeFromParameters(params)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return scheme, nil
+}
@@ -0,0 +1,164 @@
+package meds
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinParameters(test *testing.T) {
+	for _, set := range ParameterSets() {
+		scheme, _ := NewScheme(set)
+		params := scheme.Parameters()
+		if err := params.Validate(); err != nil {
+			test.Errorf("MEDS-%v: %v\n", set, err)
+			continue
+		}
+		custom, err := NewSchemeFromParameters(params)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if custom.PublicKeySize() != scheme.PublicKeySize() || custom.PrivateKeySize() != scheme.PrivateKeySize() ||
+			custom.SignatureSize() != scheme.SignatureSize() || custom.l_path != scheme.l_path {
+			test.Errorf("MEDS-%v: derived sizes differ\n", set)
+		}
+		if set != 1 && custom.ParameterSet() != set {
+			test.Errorf("MEDS-%v: custom set is named %v\n", set, custom.ParameterSet())
+		}
+	}
+}
+
+func TestValidate(test *testing.T) {
+	valid := Parameters{Q: 4093, M: 4, N: 4, K: 4, S: 3, T: 16, W: 4, L_tree_seed: 16, L_sec_seed: 32, L_pub_seed: 32, L_salt: 32, L_digest: 32}
+	if err := valid.Validate(); err != nil {
+		test.Fatal(err)
+	}
+	cases := map[string]func(*Parameters){
+		"composite q":     func(p *Parameters) { p.Q = 4095 },
+		"large q":         func(p *Parameters) { p.Q = 65537 },
+		"m != n":          func(p *Parameters) { p.N = 5 },
+		"m < 3":           func(p *Parameters) { p.M, p.N, p.K = 2, 2, 2 },
+		"k < 2":           func(p *Parameters) { p.K = 1 },
+		"k > n+1":         func(p *Parameters) { p.K = 6 },
+		"s < 2":           func(p *Parameters) { p.S = 1 },
+		"s > 256":         func(p *Parameters) { p.S = 257 },
+		"w > t":           func(p *Parameters) { p.W = 17 },
+		"w = 0":           func(p *Parameters) { p.W = 0 },
+		"t > 2^15":        func(p *Parameters) { p.T = 1<<15 + 1 },
+		"empty tree seed": func(p *Parameters) { p.L_tree_seed = 0 },
+		"empty salt":      func(p *Parameters) { p.L_salt = 0 },
+	}
+	for name, modify := range cases {
+		params := valid
+		modify(&params)
+		if err := params.Validate(); !errors.Is(err, ErrInvalidParameters) {
+			test.Errorf("%v: %v\n", name, err)
+		}
+		if _, err := NewSchemeFromParameters(params); !errors.Is(err, ErrInvalidParameters) {
+			test.Errorf("%v: NewSchemeFromParameters: %v\n", name, err)
+		}
+	}
+}
+
+func TestCustomParameters(test *testing.T) {
+	for _, params := range []Parameters{
+		{Q: 4093, M: 4, N: 4, K: 5, S: 3, T: 16, W: 4, L_tree_seed: 16, L_sec_seed: 32, L_pub_seed: 32, L_salt: 32, L_digest: 32},
+		{Q: 7, M: 3, N: 3, K: 2, S: 2, T: 3, W: 3, L_tree_seed: 8, L_sec_seed: 16, L_pub_seed: 16, L_salt: 16, L_digest: 16},
+		{Q: 65521, M: 5, N: 5, K: 4, S: 256, T: 40, W: 1, L_tree_seed: 16, L_sec_seed: 24, L_pub_seed: 32, L_salt: 8, L_digest: 48},
+	} {
+		scheme, err := NewSchemeFromParameters(params)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if scheme.Parameters() != params {
+			test.Errorf("Parameters() = %+v, expected %+v\n", scheme.Parameters(), params)
+		}
+		pk, sk, err := scheme.KeyGen(nil)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if len(pk) != scheme.PublicKeySize() || len(sk) != scheme.PrivateKeySize() || scheme.ParameterSet() != len(pk) {
+			test.Errorf("%+v: key lengths (%v, %v)\n", params, len(pk), len(sk))
+		}
+		msg_s, err := scheme.Sign(nil, sk, msg)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if len(msg_s) != scheme.SignatureSize()+len(msg) {
+			test.Errorf("%+v: signature length %v, expected %v\n", params, len(msg_s)-len(msg), scheme.SignatureSize())
+		}
+		if M, err := scheme.Verify(pk, msg_s); err != nil || !bytes.Equal(M, msg) {
+			test.Errorf("%+v: Verify: (%q, %v)\n", params, M, err)
+		}
+	}
+}
+
+// TestNoKeyPair checks that a seed whose G_0 admits no invertible A_i and B_i,
+// which happens for a small q, gives ErrNoKeyPair and KeyGen reads a new seed
+func TestNoKeyPair(test *testing.T) {
+	scheme, err := NewSchemeFromParameters(Parameters{Q: 7, M: 3, N: 3, K: 2, S: 2, T: 3, W: 3, L_tree_seed: 8, L_sec_seed: 16, L_pub_seed: 16, L_salt: 16, L_digest: 16})
+	if err != nil {
+		test.Fatal(err)
+	}
+	bad := make([]byte, 16)
+	bad[0] = 0x2c
+	if _, _, err := scheme.KeyGenFromSeed(bad); !errors.Is(err, ErrNoKeyPair) {
+		test.Fatalf("Seed without key pair: %v\n", err)
+	}
+	good := make([]byte, 16)
+	pk, sk, err := scheme.KeyGenFromSeed(good)
+	if err != nil {
+		test.Fatal(err)
+	}
+	pk_prime, sk_prime, err := scheme.KeyGen(bytes.NewReader(append(bad, good...)))
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !bytes.Equal(pk, pk_prime) || !bytes.Equal(sk, sk_prime) {
+		test.Errorf("KeyGen did not use the next seed\n")
+	}
+}
+
+func TestLoadParameters(test *testing.T) {
+	dir := test.TempDir()
+	files := map[string]string{
+		"set.json": `{"q": 4093, "m": 14, "n": 14, "k": 14, "s": 4, "t": 1152, "w": 14, "l_tree_seed": 16}`,
+		"set.toml": "# MEDS-9923\nq = 4093\nm = 14\nn = 14\nk = 14\ns = 4\nt = 1152\nw = 14\nl_tree_seed = 16\nl_salt = 32\n",
+	}
+	builtin, _ := NewScheme(9923)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		scheme, err := LoadParameters(path)
+		if err != nil {
+			test.Fatal(err)
+		}
+		if scheme.Parameters() != builtin.Parameters() || scheme.ParameterSet() != 9923 {
+			test.Errorf("%v: %+v\n", name, scheme.Parameters())
+		}
+	}
+
+	bad := map[string]string{
+		"unknown.json": `{"q": 4093, "m": 14, "n": 14, "k": 14, "s": 4, "t": 1152, "w": 14, "l_tree_seed": 16, "x": 1}`,
+		"unknown.toml": "q = 4093\nm = 14\nn = 14\nk = 14\ns = 4\nt = 1152\nw = 14\nl_tree_seed = 16\nx = 1\n",
+		"syntax.json":  `{"q": 4093,`,
+		"format.yaml":  "q: 4093\n",
+	}
+	for name, content := range bad {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := LoadParameters(path); err == nil {
+			test.Errorf("%v accepted\n", name)
+		}
+	}
+	path := filepath.Join(dir, "invalid.json")
+	os.WriteFile(path, []byte(`{"q": 4095, "m": 14, "n": 14, "k": 14, "s": 4, "t": 1152, "w": 14, "l_tree_seed": 16}`), 0o644)
+	if _, err := LoadParameters(path); !errors.Is(err, ErrInvalidParameters) {
+		test.Errorf("Composite q accepted: %v\n", err)
+	}
+	if _, err := LoadParameters(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		test.Errorf("Missing file: %v\n", err)
+	}
+}
@@ -0,0 +1,150 @@
+package meds
+
+import (
+	"bytes"
+	"io"
+	"meds/matrix"
+	"sync"
+)
+
+// PreparedPublicKey is a public key with G_0 expanded and G_1, ..., G_{s-1}
+// decompressed once, so that verification skips this setup. It is never
+// modified after NewPreparedPublicKey returns it, so it is safe for concurrent
+// use by multiple goroutines.
+type PreparedPublicKey struct {
+	pub *PublicKey
+	G_0 *matrix.Matrix
+}
+
+// NewPreparedPublicKey parses the public key bytes pk as returned by KeyGen
+// and expands G_0
+// Returns: *PreparedPublicKey, or an error if pk is not a public key of the parameter set
+func (p *Scheme) NewPreparedPublicKey(pk []byte) (*PreparedPublicKey, error) {
+	pub, err := p.parsePublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedPublicKey{pub, ExpandSystMat(pub.Sigma_G_0, p.q, p.k, p.m, p.n)}, nil
+}
+
+// Scheme returns the parameter set of the key
+func (prep *PreparedPublicKey) Scheme() *Scheme {
+	return prep.pub.scheme
+}
+
+// Bytes returns the encoded public key
+func (prep *PreparedPublicKey) Bytes() []byte {
+	return prep.pub.Bytes()
+}
+
+// Verify verifies the detached signature sig on msg
+// Returns: nil if the signature is valid, otherwise an error
+func (prep *PreparedPublicKey) Verify(msg, sig []byte) error {
+	return prep.VerifyReader(bytes.NewReader(msg), sig)
+}
+
+// VerifyReader verifies the detached signature sig on the message read from r until EOF
+// Returns: nil if the signature is valid, otherwise an error
+func (prep *PreparedPublicKey) VerifyReader(r io.Reader, sig []byte) error {
+	p := prep.pub.scheme
+	signature, err := p.parseSignature(sig)
+	if err != nil {
+		return err
+	}
+	return p.verifyExpanded(prep.pub, prep.G_0, r, signature)
+}
+
+// Open verifies the signed message msg_s as returned by Sign
+// Returns: The message if the signature is valid, otherwise an error
+func (prep *PreparedPublicKey) Open(msg_s []byte) ([]byte, error) {
+	sig, msg, err := prep.pub.scheme.SplitSignedMessage(msg_s)
+	if err != nil {
+		return nil, err
+	}
+	err = prep.Verify(msg, sig)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// PreparedPrivateKey is a secret key with A_inv and B_inv decompressed and
+// G_0 expanded once, so that signing skips this setup. Sign and its variants
+// are safe for concurrent use by multiple goroutines. Destroy wipes the
+// cached secrets, after which signing fails with ErrKeyDestroyed.
+type PreparedPrivateKey struct {
+	// mu is held for reading while signing and for writing by Destroy
+	mu        sync.RWMutex
+	priv      *PrivateKey
+	G_0       *matrix.Matrix
+	destroyed bool
+}
+
+// NewPreparedPrivateKey parses the expanded or compact secret key sk and expands G_0
+// Returns: *PreparedPrivateKey, or an error if sk is not a secret key of the parameter set
+func (p *Scheme) NewPreparedPrivateKey(sk []byte) (*PreparedPrivateKey, error) {
+	priv, err := p.parsePrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedPrivateKey{
+		priv: priv,
+		G_0:  ExpandSystMat(priv.Sigma_G_0, p.q, p.k, p.m, p.n),
+	}, nil
+}
+
+// Scheme returns the parameter set of the key
+func (prep *PreparedPrivateKey) Scheme() *Scheme {
+	return prep.priv.scheme
+}
+
+// Sign signs msg. If rng is nil, crypto/rand.Reader is used.
+// Returns: The signature followed by msg, like Scheme.Sign
+func (prep *PreparedPrivateKey) Sign(rng io.Reader, msg []byte) ([]byte, error) {
+	sig, err := prep.SignDetached(rng, msg)
+	if err != nil {
+		return nil, err
+	}
+	return prep.priv.scheme.JoinSignedMessage(sig, msg), nil
+}
+
+// SignDetached signs msg. If rng is nil, crypto/rand.Reader is used.
+// Returns: The detached signature
+func (prep *PreparedPrivateKey) SignDetached(rng io.Reader, msg []byte) ([]byte, error) {
+	return prep.SignReader(rng, bytes.NewReader(msg))
+}
+
+// SignReader signs the message read from r until EOF. If rng is nil, crypto/rand.Reader is used.
+// Returns: The detached signature
+func (prep *PreparedPrivateKey) SignReader(rng io.Reader, r io.Reader) ([]byte, error) {
+	prep.mu.RLock()
+	defer prep.mu.RUnlock()
+	if prep.destroyed {
+		return nil, ErrKeyDestroyed
+	}
+	signature, err := prep.priv.scheme.signExpanded(rng, prep.priv, prep.G_0, r)
+	if err != nil {
+		return nil, err
+	}
+	return signature.MarshalBinary()
+}
+
+// Destroy overwrites the cached secret key with zeroes. It waits for
+// signatures in progress to finish. Destroy may be called more than once.
+// The per-signature seeds and matrices are already wiped when each
+// signature is done, before their buffers go back to the pools.
+func (prep *PreparedPrivateKey) Destroy() {
+	prep.mu.Lock()
+	defer prep.mu.Unlock()
+	priv := prep.priv
+	clear(priv.Delta)
+	clear(priv.Sigma_G_0)
+	for _, M := range priv.A_inv {
+		clear(M.Data())
+	}
+	for _, M := range priv.B_inv {
+		clear(M.Data())
+	}
+	clear(prep.G_0.Data())
+	prep.destroyed = true
+}
@@ -0,0 +1,167 @@
+package meds
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPreparedPublicKey(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	prep, err := scheme.NewPreparedPublicKey(pk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if prep.Scheme() != scheme || !bytes.Equal(prep.Bytes(), pk) {
+		test.Errorf("Prepared key does not match pk\n")
+	}
+	if _, err := scheme.NewPreparedPublicKey(pk[:10]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short public key accepted: %v\n", err)
+	}
+
+	msg_s, err := scheme.Sign(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig, _, _ := scheme.SplitSignedMessage(msg_s)
+	M, err := prep.Open(msg_s)
+	if err != nil || !bytes.Equal(M, msg) {
+		test.Errorf("Open: (%q, %v)\n", M, err)
+	}
+	if err := prep.VerifyReader(bytes.NewReader(msg), sig); err != nil {
+		test.Errorf("VerifyReader: %v\n", err)
+	}
+	if err := prep.Verify([]byte("Another message"), sig); !errors.Is(err, ErrInvalidSignature) {
+		test.Errorf("Signature valid for another message: %v\n", err)
+	}
+	if _, err := prep.Open(msg_s[:10]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short signed message accepted: %v\n", err)
+	}
+
+	// The prepared key is shared by concurrent verifiers
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = prep.Verify(msg, sig)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			test.Errorf("Goroutine %v: %v\n", i, err)
+		}
+	}
+}
+
+func BenchmarkPreparedVerify9923(b *testing.B) {
+	scheme, _ := NewScheme(9923)
+	pk, sk, _ := scheme.KeyGen(nil)
+	sig, _ := scheme.SignDetached(nil, sk, msg)
+	prep, _ := scheme.NewPreparedPublicKey(pk)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := prep.Verify(msg, sig)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPreparedPrivateKey(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	prep, err := scheme.NewPreparedPrivateKey(sk)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if _, err := scheme.NewPreparedPrivateKey(sk[:10]); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short secret key accepted: %v\n", err)
+	}
+	compact, err := scheme.NewPreparedPrivateKey(sk[:scheme.CompactPrivateKeySize()])
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	// The same randomness gives the same signature as SignDetached
+	randomness := bytes.Repeat([]byte{7}, scheme.l_sec_seed)
+	E, err := scheme.SignDetached(bytes.NewReader(randomness), sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	for _, key := range []*PreparedPrivateKey{prep, compact} {
+		sig, err := key.SignDetached(bytes.NewReader(randomness), msg)
+		if err != nil || !bytes.Equal(sig, E) {
+			test.Errorf("Prepared signature differs from SignDetached: %v\n", err)
+		}
+	}
+	msg_s, err := prep.Sign(nil, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if M, err := scheme.Verify(pk, msg_s); err != nil || !bytes.Equal(M, msg) {
+		test.Errorf("Verify: (%q, %v)\n", M, err)
+	}
+
+	// Concurrent signers share the key
+	var wg sync.WaitGroup
+	sigs := make([][]byte, 8)
+	errs := make([]error, len(sigs))
+	for i := range sigs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sigs[i], errs[i] = prep.SignDetached(nil, msg)
+		}()
+	}
+	wg.Wait()
+	for i := range sigs {
+		if errs[i] == nil {
+			errs[i] = scheme.VerifyDetached(pk, msg, sigs[i])
+		}
+		if errs[i] != nil {
+			test.Errorf("Goroutine %v: %v\n", i, errs[i])
+		}
+	}
+
+	prep.Destroy()
+	prep.Destroy()
+	if _, err := prep.SignDetached(nil, msg); !errors.Is(err, ErrKeyDestroyed) {
+		test.Errorf("Signed with a destroyed key: %v\n", err)
+	}
+	secrets := [][]byte{prep.priv.Delta, prep.priv.Sigma_G_0}
+	for i := range prep.priv.A_inv {
+		secrets = append(secrets, prep.priv.A_inv[i].Compress(), prep.priv.B_inv[i].Compress())
+	}
+	secrets = append(secrets, prep.G_0.Compress())
+	for i, b := range secrets {
+		if !bytes.Equal(b, make([]byte, len(b))) {
+			test.Errorf("Secret %v is not wiped: %v\n", i, b)
+		}
+	}
+}
+
+func BenchmarkPreparedSign9923(b *testing.B) {
+	scheme, _ := NewScheme(9923)
+	_, sk, _ := scheme.KeyGen(nil)
+	prep, _ := scheme.NewPreparedPrivateKey(sk)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := prep.SignDetached(nil, msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
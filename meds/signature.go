@@ -0,0 +1,124 @@
+package meds
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"meds/matrix"
+)
+
+// Signature is a detached MEDS signature parsed into its parts
+type Signature struct {
+	scheme *Scheme
+	// Mu and Nu hold the w responses of the challenged rounds in round order
+	Mu     []*matrix.Matrix
+	Nu     []*matrix.Matrix
+	Path   []byte
+	Digest []byte
+	Salt   []byte
+}
+
+// NewSignature parses the detached signature sig as returned by SignDetached
+// Returns: *Signature, or an error if sig is not a signature of the parameter set
+func (p *Scheme) NewSignature(sig []byte) (*Signature, error) {
+	return p.parseSignature(sig)
+}
+
+func (p *Scheme) parseSignature(sig []byte) (*Signature, error) {
+	if len(sig) != p.l_sig {
+		return nil, fmt.Errorf("%w: signature is %v bytes, expected %v", ErrWrongLength, len(sig), p.l_sig)
+	}
+	signature := &Signature{
+		scheme: p,
+		Mu:     matrix.NewBatch(p.w, p.m, p.m, p.q),
+		Nu:     matrix.NewBatch(p.w, p.n, p.n, p.q),
+	}
+	// compressed holds the re-encoding of one response for the canonical check
+	compressed := make([]byte, 0, max(p.l_f_mm, p.l_f_nn))
+	f_sig := 0
+	for i := 0; i < p.w; i++ {
+		b := sig[f_sig : f_sig+p.l_f_mm]
+		matrix.DecompressInto(signature.Mu[i], b)
+		if !bytes.Equal(signature.Mu[i].AppendCompress(compressed[:0]), b) {
+			return nil, fmt.Errorf("%w: mu_%v is not canonically encoded", ErrMalformedSignature, i)
+		}
+		f_sig += p.l_f_mm
+		b = sig[f_sig : f_sig+p.l_f_nn]
+		matrix.DecompressInto(signature.Nu[i], b)
+		if !bytes.Equal(signature.Nu[i].AppendCompress(compressed[:0]), b) {
+			return nil, fmt.Errorf("%w: nu_%v is not canonically encoded", ErrMalformedSignature, i)
+		}
+		f_sig += p.l_f_nn
+	}
+	signature.Path = bytes.Clone(sig[f_sig : f_sig+p.l_path])
+	f_sig += p.l_path
+	signature.Digest = bytes.Clone(sig[f_sig : f_sig+p.l_digest])
+	f_sig += p.l_digest
+	signature.Salt = bytes.Clone(sig[f_sig : f_sig+p.l_salt])
+	return signature, nil
+}
+
+// Scheme returns the parameter set of the signature
+func (signature *Signature) Scheme() *Scheme {
+	return signature.scheme
+}
+
+// check returns an error unless every field of signature has the length and
+// shape of its parameter set
+func (signature *Signature) check() error {
+	if signature == nil || signature.scheme == nil {
+		return errors.New("meds: signature has no parameter set")
+	}
+	p := signature.scheme
+	if len(signature.Mu) != p.w || len(signature.Nu) != p.w || len(signature.Path) != p.l_path ||
+		len(signature.Digest) != p.l_digest || len(signature.Salt) != p.l_salt {
+		return fmt.Errorf("%w: signature does not match MEDS-%v", ErrWrongLength, p.set)
+	}
+	for i := 0; i < p.w; i++ {
+		if !hasShape(signature.Mu[i], p.m, p.q) {
+			return fmt.Errorf("%w: mu_%v is not a %v x %v matrix over F_%v", ErrMalformedSignature, i, p.m, p.m, p.q)
+		}
+		if !hasShape(signature.Nu[i], p.n, p.q) {
+			return fmt.Errorf("%w: nu_%v is not a %v x %v matrix over F_%v", ErrMalformedSignature, i, p.n, p.n, p.q)
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes the signature as
+// mu_0 || nu_0 || ... || mu_{w-1} || nu_{w-1} || path || digest || salt
+func (signature *Signature) MarshalBinary() ([]byte, error) {
+	err := signature.check()
+	if err != nil {
+		return nil, err
+	}
+	p := signature.scheme
+	sig := make([]byte, 0, p.l_sig)
+	for i := 0; i < p.w; i++ {
+		sig = signature.Mu[i].AppendCompress(sig)
+		sig = signature.Nu[i].AppendCompress(sig)
+	}
+	sig = append(sig, signature.Path...)
+	sig = append(sig, signature.Digest...)
+	sig = append(sig, signature.Salt...)
+	return sig, nil
+}
+
+// UnmarshalBinary decodes a detached signature. If signature has no parameter
+// set yet, it is selected among the built-in sets by the length of sig.
+func (signature *Signature) UnmarshalBinary(sig []byte) error {
+	p := signature.scheme
+	if p == nil {
+		var err error
+		p, err = schemeForSize(len(sig), (*Scheme).SignatureSize)
+		if err != nil {
+			return err
+		}
+	}
+	parsed, err := p.parseSignature(sig)
+	if err != nil {
+		return err
+	}
+	*signature = *parsed
+	return nil
+}
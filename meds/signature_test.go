@@ -0,0 +1,128 @@
+package meds
+
+import (
+	"bytes"
+	"errors"
+	"meds/matrix"
+	"meds/seedTree"
+	"testing"
+)
+
+func TestSignatureEncoding(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig, err := scheme.SignDetached(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var signature Signature
+	if err := signature.UnmarshalBinary(sig); err != nil {
+		test.Fatal(err)
+	}
+	if signature.Scheme().ParameterSet() != 1 || len(signature.Mu) != scheme.w || len(signature.Nu) != scheme.w {
+		test.Errorf("Signature parsed with wrong parameters\n")
+	}
+	b, err := signature.MarshalBinary()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if !bytes.Equal(b, sig) {
+		test.Errorf("Signature round trip failed\n")
+	}
+	pub, _ := scheme.NewPublicKey(pk)
+	if err := pub.Verify(msg, b); err != nil {
+		test.Errorf("Invalid signature: %v\n", err)
+	}
+	if err := signature.UnmarshalBinary(append(sig, 0)); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Long signature accepted: %v\n", err)
+	}
+	bad := bytes.Clone(sig)
+	bad[0] = 0xFF
+	bad[1] = 0xFF
+	if err := scheme.VerifyDetached(pk, msg, bad); !errors.Is(err, ErrMalformedSignature) {
+		test.Errorf("Non-canonical signature accepted: %v\n", err)
+	}
+	signature.Salt = signature.Salt[1:]
+	if _, err := signature.MarshalBinary(); !errors.Is(err, ErrWrongLength) {
+		test.Errorf("Short salt encoded: %v\n", err)
+	}
+}
+
+func TestModifiedSignature(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	_, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig, err := scheme.SignDetached(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	modifications := map[string]func(signature *Signature){
+		"small mu_0": func(signature *Signature) { signature.Mu[0] = matrix.New(2, 2, scheme.q) },
+		"nil mu_1":   func(signature *Signature) { signature.Mu[1] = nil },
+		"wide nu_0":  func(signature *Signature) { signature.Nu[0] = matrix.New(scheme.n, scheme.n+1, scheme.q) },
+		"other q":    func(signature *Signature) { signature.Nu[1] = matrix.New(scheme.n, scheme.n, 2039) },
+	}
+	for name, modify := range modifications {
+		signature, err := scheme.NewSignature(sig)
+		if err != nil {
+			test.Fatal(err)
+		}
+		modify(signature)
+		if _, err := signature.MarshalBinary(); !errors.Is(err, ErrMalformedSignature) {
+			test.Errorf("%v: MarshalBinary returned %v\n", name, err)
+		}
+	}
+	var signature *Signature
+	if _, err := signature.MarshalBinary(); err == nil {
+		test.Errorf("nil signature encoded\n")
+	}
+}
+
+// TestSignaturePadding checks that a signature whose seed tree path has
+// non-zero padding is rejected, so a valid signature cannot be altered into
+// another valid signature
+func TestSignaturePadding(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	pk, sk, err := scheme.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	for tries := 0; tries < 10; tries++ {
+		sig, err := scheme.SignDetached(nil, sk, msg)
+		if err != nil {
+			test.Fatal(err)
+		}
+		signature, err := scheme.NewSignature(sig)
+		if err != nil {
+			test.Fatal(err)
+		}
+		// used is the length of the revealed seeds, the shortest prefix of the path that is accepted
+		h := ParseHash(scheme.s, scheme.t, scheme.w, signature.Digest)
+		tree := seedTree.NewTree(scheme.t, scheme.l_tree_seed)
+		used := 0
+		for tree.FromPath(h, signature.Path[:used], signature.Salt) != nil {
+			used += scheme.l_tree_seed
+		}
+		if used == scheme.l_path {
+			continue
+		}
+		for _, i := range []int{used, scheme.l_path - 1} {
+			signature.Path[i] ^= 1
+			b, err := signature.MarshalBinary()
+			if err != nil {
+				test.Fatal(err)
+			}
+			if err := scheme.VerifyDetached(pk, msg, b); !errors.Is(err, ErrMalformedSignature) {
+				test.Errorf("Signature with non-zero padding byte %v accepted: %v\n", i, err)
+			}
+			signature.Path[i] ^= 1
+		}
+		return
+	}
+	test.Skip("no signature with padding in the seed tree path")
+}
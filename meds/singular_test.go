@@ -0,0 +1,79 @@
+package meds
+
+import (
+	"bytes"
+	"meds/finiteField"
+	"meds/matrix"
+	"testing"
+	"time"
+)
+
+// singularLeading returns a 3 x 6 matrix whose leading 3 x 3 submatrix is
+// singular because its first two columns are equal
+func singularLeading(q int) *matrix.Matrix {
+	M := matrix.New(3, 6, q)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 6; j++ {
+			M.Set(i, j, finiteField.NewFieldElm(1+7*i+3*j+i*j*j, q))
+		}
+		M.Set(i, 1, M.Get(i, 0))
+	}
+	return M
+}
+
+func TestSFSingular(test *testing.T) {
+	M := singularLeading(4093)
+	if sf := SF(M); sf != nil {
+		test.Errorf("SF of a singular leading submatrix:\n%v", sf)
+	}
+	if err := SF_on_submatrix(M, 0, 0, 3, 6); err == nil {
+		test.Errorf("SF_on_submatrix accepted a singular leading submatrix\n")
+	}
+
+	// Making the leading submatrix the identity gives a systematic form
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			M.Set(i, j, finiteField.NewFieldElm(0, 4093))
+		}
+		M.Set(i, i, finiteField.NewFieldElm(1, 4093))
+	}
+	sf := SF(M)
+	if sf == nil || !sf.Equals(M) {
+		test.Errorf("SF of a systematic matrix:\n%v", sf)
+	}
+	if err := SF_on_submatrix(M, 0, 0, 3, 6); err != nil {
+		test.Errorf("SF_on_submatrix: %v\n", err)
+	}
+}
+
+func TestKeyGenResample(test *testing.T) {
+	scheme, _ := NewScheme(1)
+	// For this seed one G_i has no systematic form, and A and B must be resampled
+	delta := make([]byte, scheme.l_sec_seed)
+	delta[0], delta[1] = 0x89, 0x01
+	type keys struct {
+		pk, sk []byte
+		err    error
+	}
+	done := make(chan keys, 1)
+	go func() {
+		pk, sk, err := scheme.KeyGenFromSeed(delta)
+		done <- keys{pk, sk, err}
+	}()
+	var k keys
+	select {
+	case k = <-done:
+	case <-time.After(time.Minute):
+		test.Fatalf("KeyGen does not resample A and B\n")
+	}
+	if k.err != nil {
+		test.Fatal(k.err)
+	}
+	msg_s, err := scheme.Sign(nil, k.sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if M, err := scheme.Verify(k.pk, msg_s); err != nil || !bytes.Equal(M, msg) {
+		test.Errorf("Verify: (%q, %v)\n", M, err)
+	}
+}
@@ -0,0 +1,160 @@
+package meds
+
+import (
+	"math"
+	"math/rand"
+	"meds/finiteField"
+	"meds/matrix"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// dudect measures op on inputs of two classes in random order and compares
+// the timings with Welch's t-test, as in "Dude, is my code constant time?"
+// (Reparaz, Balasch and Verbauwhede, 2017). Measurements above the 90th
+// percentile are cropped since they are mostly interrupts and GC.
+// Returns: The t statistic
+func dudect(n int, input func(class int) func()) float64 {
+	classes := make([]int, n)
+	ops := make([]func(), n)
+	for i := 0; i < n; i++ {
+		classes[i] = rand.Intn(2)
+		ops[i] = input(classes[i])
+	}
+	times := make([]float64, n)
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		ops[i]()
+		times[i] = float64(time.Since(start))
+	}
+
+	sorted := append([]float64{}, times...)
+	sort.Float64s(sorted)
+	crop := sorted[n*9/10]
+	var count [2]float64
+	var mean [2]float64
+	var m2 [2]float64
+	for i, x := range times {
+		if x > crop {
+			continue
+		}
+		c := classes[i]
+		count[c]++
+		delta := x - mean[c]
+		mean[c] += delta / count[c]
+		m2[c] += delta * (x - mean[c])
+	}
+	variance0 := m2[0] / (count[0] - 1)
+	variance1 := m2[1] / (count[1] - 1)
+	return (mean[0] - mean[1]) / math.Sqrt(variance0/count[0]+variance1/count[1])
+}
+
+// randomize fills M with random elements in place
+func randomize(M *matrix.Matrix) {
+	for i := 0; i < M.M; i++ {
+		for j := 0; j < M.N; j++ {
+			M.SetValue(i, j, rand.Intn(M.Q))
+		}
+	}
+}
+
+// TestConstantTime checks that SF, SF_on_submatrix and field inversion run
+// in the same time on inputs that need the most row swaps as on random inputs.
+// It also compares Invertable on the reversed identity and random matrices,
+// Decompress on zero and random encodings, and the reduction of Fq on zero
+// and random elements.
+// Timing depends on the machine, so it only runs when asked for:
+//
+//	MEDS_DUDECT=1 go test ./meds -run TestConstantTime -v
+func TestConstantTime(test *testing.T) {
+	if os.Getenv("MEDS_DUDECT") == "" {
+		test.Skip("set MEDS_DUDECT=1 to run the timing test")
+	}
+	const threshold = 10
+	const samples = 200000
+	q, m := 4093, 14
+	// Every pivot of the reversed identity is zero until the last row is swapped up.
+	// The inputs of both classes are allocated the same way, since the alignment
+	// of a matrix alone changes its timing measurably.
+	reversed := matrix.New(m, 2*m, q)
+	for i := 0; i < m; i++ {
+		reversed.SetValue(i, m-1-i, 1)
+		reversed.SetValue(i, m+i, 1)
+	}
+
+	t := dudect(samples/10, func(class int) func() {
+		M := reversed.Copy()
+		if class == 1 {
+			randomize(M)
+		}
+		return func() { SF(M) }
+	})
+	test.Logf("SF: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("SF timing depends on the input: t = %.2f\n", t)
+	}
+
+	t = dudect(samples/10, func(class int) func() {
+		M := reversed.Copy()
+		if class == 1 {
+			randomize(M)
+		}
+		return func() { SF_on_submatrix(M, 0, 0, m, 2*m) }
+	})
+	test.Logf("SF_on_submatrix: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("SF_on_submatrix timing depends on the input: t = %.2f\n", t)
+	}
+
+	I := matrix.Identity(m, q)
+	t = dudect(samples/10, func(class int) func() {
+		M := reversed.Submatrix(0, m, 0, m)
+		if class == 1 {
+			randomize(M)
+		}
+		return func() { Invertable(M, I) }
+	})
+	test.Logf("Invertable: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("Invertable timing depends on the input: t = %.2f\n", t)
+	}
+
+	t = dudect(samples, func(class int) func() {
+		b := make([]byte, matrix.CompressedSize(m, m, q))
+		if class == 1 {
+			rand.Read(b)
+		}
+		M := matrix.New(m, m, q)
+		return func() { matrix.DecompressInto(M, b) }
+	})
+	test.Logf("Decompress: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("Decompress timing depends on the input: t = %.2f\n", t)
+	}
+
+	t = dudect(samples, func(class int) func() {
+		x := finiteField.NewFieldElm(0, q)
+		if class == 1 {
+			x.Set(1 + rand.Intn(q-1))
+		}
+		return func() { x.UnaryMinus() }
+	})
+	test.Logf("Fq.UnaryMinus: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("Fq.UnaryMinus timing depends on the input: t = %.2f\n", t)
+	}
+
+	t = dudect(samples, func(class int) func() {
+		x := finiteField.NewFieldElm(1, q)
+		if class == 1 {
+			x.Set(1 + rand.Intn(q-1))
+		}
+		return func() { x.Inv() }
+	})
+	test.Logf("Fq.Inv: t = %.2f\n", t)
+	if math.Abs(t) > threshold {
+		test.Errorf("Fq.Inv timing depends on the input: t = %.2f\n", t)
+	}
+}
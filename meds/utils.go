@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"meds/finiteField"
 	"meds/matrix"
@@ -14,25 +15,42 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
-func Randombytes(n int) []byte {
+// Randombytes reads n bytes from rng, or from crypto/rand.Reader if rng is nil
+// Returns: []byte of length n, or an error if rng fails
+func Randombytes(rng io.Reader, n int) ([]byte, error) {
 	b := make([]byte, n)
-	rand.Read(b)
-	return b
+	err := readRandom(rng, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readRandom is Randombytes filling b instead of allocating it
+func readRandom(rng io.Reader, b []byte) error {
+	if rng == nil {
+		rng = rand.Reader
+	}
+	_, err := io.ReadFull(rng, b)
+	if err != nil {
+		return fmt.Errorf("meds: reading randomness: %w", err)
+	}
+	return nil
 }
 
 // CompressG compresses the given matrix.Matrix to a []byte
 // Returns: []byte with length as specified in MEDS specification document
-func CompressG(G *matrix.Matrix) []byte {
+func CompressG(G *matrix.Matrix, m, n, k int) []byte {
 	l_g_prime := (k-2)*(G.N-k) + n
 	G_prime := matrix.New(1, l_g_prime, G.Q)
 	idx := 0
 	for i := 0; i < n; i++ {
-		G_prime.Set(0, idx, G.Get(1, m*n-n+i))
+		G_prime.SetValue(0, idx, G.GetValue(1, m*n-n+i))
 		idx++
 	}
 	for i := 2; i < k; i++ {
 		for j := k; j < m*n; j++ {
-			G_prime.Set(0, idx, G.Get(i, j))
+			G_prime.SetValue(0, idx, G.GetValue(i, j))
 			idx++
 		}
 	}
@@ -48,23 +66,23 @@ func DecompressG(b []byte, q int, m, n, k int) *matrix.Matrix {
 	G := matrix.New(k, m*n, q)
 	// Making the first k by k submatrix the identity matrix
 	for i := 0; i < G.M; i++ {
-		G.Get(i, i).Set(1)
+		G.SetValue(i, i, 1)
 	}
 
 	for i := 1; i < m; i++ {
-		G.Get(0, i*(n+1)).Set(1)
+		G.SetValue(0, i*(n+1), 1)
 	}
 	for i := 1; i < m-1; i++ {
-		G.Get(1, i*(n+1)+1).Set(1)
+		G.SetValue(1, i*(n+1)+1, 1)
 	}
 	idx := 0
 	for i := 0; i < n; i++ {
-		G.Set(1, m*n-n+i, G_prime.Get(0, idx))
+		G.SetValue(1, m*n-n+i, G_prime.GetValue(0, idx))
 		idx++
 	}
 	for i := 2; i < k; i++ {
 		for j := k; j < m*n; j++ {
-			G.Set(i, j, G_prime.Get(0, idx))
+			G.SetValue(i, j, G_prime.GetValue(0, idx))
 			idx++
 		}
 	}
@@ -86,34 +104,39 @@ func ExpandFqs(seed []byte, l, q int) []*finiteField.Fq {
 }
 
 func expandFqs(shake sha3.ShakeHash, q int) *finiteField.Fq {
-	m := int(math.Pow(2, float64(Bitlen(q))))
-	byte_len := Bytelen(q)
-	buf := make([]byte, 1)
-EXPAND_LOOP:
-	a := 0
-	for j := 0; j < byte_len; j++ {
+	return finiteField.NewFieldElm(expandFq(shake, q, make([]byte, Bytelen(q))), q)
+}
+
+// expandFq samples an element of F_q from shake by rejection sampling.
+// buf holds the Bytelen(q) bytes read per attempt, so the caller can reuse it.
+// Returns: An integer in [0, q)
+func expandFq(shake sha3.ShakeHash, q int, buf []byte) int {
+	mask := 1<<Bitlen(q) - 1
+	for {
 		shake.Read(buf)
-		a += int(buf[0]) * int(math.Pow(2, float64(8*j)))
-	}
-	a %= m
-	if a >= q {
-		goto EXPAND_LOOP
+		a := 0
+		for j, b := range buf {
+			a |= int(b) << (8 * j)
+		}
+		a &= mask
+		if a < q {
+			return a
+		}
 	}
-	return finiteField.NewFieldElm(a, q)
 }
 
 // ExpandSystMat generates a matrix in systematic form from the given seed
 // Returns: Matrix $M \in F_{q}^{k \times mn}$
 func ExpandSystMat(seed []byte, q, k, m, n int) *matrix.Matrix {
-	a := ExpandFqs(seed, k*(m*n-k), q)
-	f_a := 0
+	shake := sha3.NewShake256()
+	shake.Write(seed)
+	buf := make([]byte, Bytelen(q))
 	M := matrix.New(k, m*n, q)
 
 	for i := 0; i < k; i++ {
-		M.Get(i, i).Set(1)
+		M.SetValue(i, i, 1)
 		for j := k; j < m*n; j++ {
-			M.Set(i, j, a[f_a])
-			f_a++
+			M.SetValue(i, j, expandFq(shake, q, buf))
 		}
 	}
 
@@ -149,33 +172,43 @@ func MatriciesToRows(P []*matrix.Matrix) *matrix.Matrix {
 }
 
 func Pi(A, G, B *matrix.Matrix) *matrix.Matrix {
-	P := RowsToMatricies(G, A.M, B.N)
-	P_prime := make([]*matrix.Matrix, G.M)
+	R := matrix.New(G.M, G.N, G.Q)
+	piInto(R, A, G, B, matrix.New(A.M, B.N, G.Q), matrix.New(A.M, B.N, G.Q))
+	return R
+}
 
+// piInto computes Pi(A, G, B) into R. Row i of G is read as the m x n matrix
+// P_i and row i of R is set to A P_i B. P and AP are m x n scratch matrices.
+func piInto(R, A, G, B, P, AP *matrix.Matrix) {
 	for i := 0; i < G.M; i++ {
-		P_prime[i] = A.Mul(P[i]).Mul(B)
+		copy(P.Data(), G.Row(i))
+		A.MulInto(P, AP)
+		AP.MulInto(B, P)
+		copy(R.Row(i), P.Data())
 	}
-
-	return MatriciesToRows(P_prime)
 }
 
 func ExpandInvMat(seed []byte, q, d int) *matrix.Matrix {
-	shake := sha3.NewShake256()
-	shake.Write(seed)
 	M := matrix.New(d, d, q)
-	I := matrix.Identity(d, q)
-INVERTABLE_LOOP:
-	for i := 0; i < d; i++ {
-		for j := 0; j < d; j++ {
-			M.Set(i, j, expandFqs(shake, q))
+	expandInvMat(M, matrix.New(d, d, q), sha3.NewShake256(), seed, make([]byte, Bytelen(q)))
+	return M
+}
+
+// expandInvMat is ExpandInvMat writing into the d x d matrix M. shake is
+// reset before use, scratch is a d x d matrix for the invertibility check
+// and buf holds Bytelen(q) bytes.
+func expandInvMat(M, scratch *matrix.Matrix, shake sha3.ShakeHash, seed, buf []byte) {
+	shake.Reset()
+	shake.Write(seed)
+	for {
+		data := M.Data()
+		for i := range data {
+			data[i] = uint16(expandFq(shake, M.Q, buf))
+		}
+		if invertible(M, scratch) {
+			return
 		}
 	}
-	M_sf := SF(M)
-	if M_sf == nil || !M_sf.Equals(I) {
-		goto INVERTABLE_LOOP
-	}
-
-	return M
 }
 
 func Bitlen(x int) int {
@@ -218,68 +251,65 @@ func ParseHash(s, t, w int, d []byte) []byte {
 	return h
 }
 
-func swapElm(M *matrix.Matrix, i, j, k int) {
-	tmp := M.Get(i, k)
-	M.Set(i, k, M.Get(j, k))
-	M.Set(j, k, tmp)
-}
-
-func multFixedConst(M *matrix.Matrix, i int, c *finiteField.Fq) {
-	for j := 0; j < M.N; j++ {
-		M.Set(i, j, M.Get(i, j).Mul(c))
+// multRow multiplies every element of row by c
+func multRow(f finiteField.Field, row []uint16, c uint32) {
+	for j, v := range row {
+		row[j] = uint16(f.Mul(uint32(v), c))
 	}
 }
 
-func swapRows(M *matrix.Matrix, i, j int) {
-	for k := 0; k < M.N; k++ {
-		swapElm(M, i, j, k)
-	}
+// ctIsZero returns 0xFFFF if v is 0 and 0 otherwise, without branching
+func ctIsZero(v uint16) uint16 {
+	return uint16(-((uint32(v) - 1) >> 31))
 }
 
-func constTimesEq1PlusEq2(M *matrix.Matrix, c *finiteField.Fq, i, j int) {
-	for k := 0; k < M.N; k++ {
-		M.Set(j, k, M.Get(j, k).Add(M.Get(i, k).Mul(c)))
+// condSwapRows swaps row_i and row_j if mask is 0xFFFF and leaves them
+// unchanged if mask is 0, with the same memory accesses in both cases
+func condSwapRows(row_i, row_j []uint16, mask uint16) {
+	for k := range row_i {
+		t := (row_i[k] ^ row_j[k]) & mask
+		row_i[k] ^= t
+		row_j[k] ^= t
 	}
 }
 
-func zeroCol(M *matrix.Matrix, j int) bool {
-	zero_col := true
-	zero := finiteField.NewFieldElm(0, M.Q)
-
-	for i := 0; i < M.M && zero_col; i++ {
-		zero_col = M.Get(i, j).Equals(zero)
+// constTimesEq1PlusEq2 adds c times row_i to row_j with one reduction per element
+func constTimesEq1PlusEq2(f finiteField.Field, c uint32, row_i, row_j []uint16) {
+	for k, v := range row_i {
+		row_j[k] = uint16(f.Reduce(uint64(row_j[k]) + uint64(c)*uint64(v)))
 	}
-
-	return zero_col
 }
 
+// SF computes the systematic form of M by Gauss-Jordan elimination.
+// It runs in constant time: a zero pivot is replaced by masked row swaps
+// with every row below it, pivots are inverted by Fermat exponentiation and
+// a singular leading submatrix is only reported after all columns are done.
+// Returns: The systematic form, or nil if the leading M.M x M.M submatrix is singular
 func SF(M *matrix.Matrix) *matrix.Matrix {
-	sf := matrix.New(M.M, M.N, M.Q)
-	for i := 0; i < M.M; i++ {
-		for j := 0; j < M.N; j++ {
-			sf.Set(i, j, finiteField.NewFromBytes(M.Get(i, j).Bytes(), M.Q))
-		}
-	}
-	zero := finiteField.NewFieldElm(0, M.Q)
+	sf := M.Copy()
+	f := finiteField.NewField(M.Q)
+	singular := uint16(0)
 
 	for i := 0; i < sf.M; i++ {
 		l := i
-		if zeroCol(sf, l) {
-			return nil
+		for k := i + 1; k < sf.M; k++ {
+			condSwapRows(sf.Row(i), sf.Row(k), ctIsZero(sf.Row(i)[l]))
 		}
-		for k := i + 1; k < sf.M && sf.Get(i, l).Equals(zero); k++ {
-			swapRows(sf, i, k)
-		}
-		multFixedConst(sf, i, sf.Get(i, l).Inv())
+		singular |= ctIsZero(sf.Row(i)[l])
+		multRow(f, sf.Row(i), f.Inv(uint32(sf.Row(i)[l])))
 		for k := 0; k < sf.M; k++ {
 			if k == i {
 				continue
 			}
-			c := sf.Get(k, l).UnaryMinus()
-			constTimesEq1PlusEq2(sf, c, i, k)
+			c := f.Neg(uint32(sf.Row(k)[l]))
+			constTimesEq1PlusEq2(f, c, sf.Row(i), sf.Row(k))
 		}
 	}
 
+	// The leading square submatrix is singular
+	if singular != 0 {
+		return nil
+	}
 	return sf
 }
 
@@ -441,127 +471,84 @@ func solve_sub_matricies(rsys *matrix.Matrix, m, n int) error {
 	return nil
 }
 
+// SF_on_submatrix computes the systematic form of the m x n submatrix of M
+// starting at (row, col) in place, in constant time like SF.
+// Returns: nil, or an error if the leading m x m submatrix is singular
 func SF_on_submatrix(M *matrix.Matrix, row, col, m, n int) error {
-	zero := finiteField.NewFieldElm(0, M.Q)
+	f := finiteField.NewField(M.Q)
+	sub_row := func(i int) []uint16 {
+		return M.Row(row + i)[col : col+n]
+	}
+	singular := uint16(0)
 
-	failed := false
 	for i := 0; i < m; i++ {
 		l := i
-		if zero_col_submatrix(M, l, row, col, m, zero) {
-			return fmt.Errorf("includes zero col %v", l)
-		}
-		for k := i + 1; k < m && M.Get(row+i, col+l).Equals(zero); k++ {
-			swap_rows_submatrix(M, i, k, row, col, n)
+		for k := i + 1; k < m; k++ {
+			condSwapRows(sub_row(i), sub_row(k), ctIsZero(sub_row(i)[l]))
 		}
-		mult_fixed_const_submatrix(M, i, M.Get(row+i, col+l).Inv(), row, col, n)
+		singular |= ctIsZero(sub_row(i)[l])
+		multRow(f, sub_row(i), f.Inv(uint32(sub_row(i)[l])))
 		for k := 0; k < m; k++ {
 			if k == i {
 				continue
 			}
-			c := M.Get(row+k, col+l).UnaryMinus()
-			const_times_eq1_plus_eq2_submatrix(M, c, i, k, row, col, n)
+			c := f.Neg(uint32(sub_row(k)[l]))
+			constTimesEq1PlusEq2(f, c, sub_row(i), sub_row(k))
 		}
 	}
-	if failed {
-		return errors.New("failed sf of submatrix")
+	if singular != 0 {
+		return errors.New("singular leading submatrix")
 	}
 	return nil
 }
 
-func mult_fixed_const_submatrix(M *matrix.Matrix, i int, c *finiteField.Fq, row, col, n int) {
-	for j := 0; j < n; j++ {
-		M.Set(row+i, col+j, M.Get(row+i, col+j).Mul(c))
-	}
-}
-
-func swap_rows_submatrix(M *matrix.Matrix, i, j, row, col, n int) {
-	for k := 0; k < n; k++ {
-		swapElm(M, row+i, row+j, col+k)
-	}
-}
-
-func const_times_eq1_plus_eq2_submatrix(M *matrix.Matrix, c *finiteField.Fq, i, j, row, col, n int) {
-	for k := 0; k < n; k++ {
-		M.Set(row+j, col+k, M.Get(row+j, col+k).Add(M.Get(row+i, col+k).Mul(c)))
-	}
-}
-
-func zero_col_submatrix(M *matrix.Matrix, j, row, col, m int, zero *finiteField.Fq) bool {
-	zero_col := true
-
-	for i := 0; i < m && zero_col; i++ {
-		zero_col = M.Get(row+i, col+j).Equals(zero)
-	}
-
-	return zero_col
-}
-
 func backprop_to_sf(M *matrix.Matrix, m int) {
-	zero := finiteField.NewFieldElm(0, M.Q)
+	f := finiteField.NewField(M.Q)
 	col := M.N - 2
 	for row := M.M - 1; row >= m*m; row-- {
 		for i := 0; i < row; i++ {
-			if M.Get(i, col).Equals(zero) {
-				continue
-			}
-			c := M.Get(i, col).UnaryMinus()
-			constTimesEq1PlusEq2(M, c, row, i)
+			c := f.Neg(uint32(M.GetValue(i, col)))
+			constTimesEq1PlusEq2(f, c, M.Row(row), M.Row(i))
 		}
 		col--
 	}
 }
 
+// SeedTree derives the t leaf seeds of the seed tree with root seed
+// Returns: The leaf seeds in order
 func SeedTree(seed, salt []byte, t int) ([][]byte, error) {
-	treeHeight := int(math.Ceil(math.Log2(float64(t))))
-	root := seedTree.New(seed, salt, 0, 0, nil, nil, nil)
-	leafs := make([]*seedTree.SeedTreeNode, t)
-	err := root.CreateSeedTree(treeHeight, &leafs)
-	if err != nil {
-		return [][]byte{}, err
-	}
-
+	tree := seedTree.NewTree(t, len(seed))
+	tree.Generate(seed, salt)
 	seeds := make([][]byte, t)
 	for i := 0; i < t; i++ {
-		seeds[i] = leafs[i].Seed()
+		seeds[i] = tree.Leaf(i)
 	}
 
 	return seeds, nil
 }
 
+// SeedTreeToPath derives the seed tree with root seed and reveals the seeds
+// needed for the rounds i with digest[i] == 0
+// Returns: The path, zero-padded to its length in the specification
 func SeedTreeToPath(w, t int, digest, seed, salt []byte) []byte {
 	l_path := (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(int(math.Ceil(math.Log2(float64(t))))-int(math.Ceil(math.Log2(float64(w))))-1)) * len(seed)
 	path := make([]byte, l_path)
-	treeHeight := int(math.Ceil(math.Log2(float64(t))))
-	root := seedTree.New(seed, salt, 0, 0, nil, nil, nil)
-	leafs := make([]*seedTree.SeedTreeNode, t)
-	root.CreateSeedTree(treeHeight, &leafs)
-	for i := 0; i < t; i++ {
-		if digest[i] != byte(0) {
-			leafs[i].RemoveSeedLabel()
-		}
-	}
-	idx := 0
-	root.SeedTreeToPath(&path, &idx)
+	tree := seedTree.NewTree(t, len(seed))
+	tree.Generate(seed, salt)
+	tree.ToPath(digest, path)
 	return path
 }
 
+// PathToSeedTree rebuilds the leaf seeds of the rounds i with digest[i] == 0 from path
+// Returns: The t leaf seeds, where those of the challenged rounds are zero
 func PathToSeedTree(digest, path, salt []byte, l_tree_seed int) [][]byte {
 	t := len(digest)
-	treeHeight := int(math.Ceil(math.Log2(float64(t))))
-	leafs := make([]*seedTree.SeedTreeNode, t)
-	root := seedTree.EmptyTree(treeHeight, salt, &leafs)
-	for i := 0; i < t; i++ {
-		if digest[i] != byte(0) {
-			leafs[i].RemoveSeedLabel()
-		}
-	}
+	tree := seedTree.NewTree(t, l_tree_seed)
+	tree.FromPath(digest, path, salt)
 	seeds := make([][]byte, t)
 	for i := 0; i < t; i++ {
-		seeds[i] = make([]byte, l_tree_seed)
+		seeds[i] = tree.Leaf(i)
 	}
-	seeds_idx := 0
-	path_idx := 0
-	root.PathToSeedTree(&path, l_tree_seed, &seeds, &seeds_idx, &path_idx)
 	return seeds
 }
 
@@ -574,10 +561,20 @@ func ToBytes(a int32, l int) ([]byte, error) {
 	return buf.Bytes()[:l], nil
 }
 
+// invertible reports whether the square matrix M is invertible,
+// using scratch, a matrix of the same size, for the elimination
+func invertible(M, scratch *matrix.Matrix) bool {
+	copy(scratch.Data(), M.Data())
+	return SF_on_submatrix(scratch, 0, 0, M.M, M.N) == nil
+}
+
+// Invertable reports whether the square matrix M is invertible, i.e. its
+// systematic form is the identity I. M is secret during key generation, so
+// the systematic form is compared without an early exit.
 func Invertable(M, I *matrix.Matrix) bool {
 	M_sf := SF(M)
 	if M_sf == nil {
 		return false
 	}
-	return M_sf.Equals(I)
+	return M_sf.ConstantTimeEquals(I)
 }
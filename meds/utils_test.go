@@ -1,10 +1,13 @@
 package meds
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"meds/finiteField"
 	"meds/matrix"
+	"meds/seedTree"
 	"os"
 	"os/exec"
 	"strconv"
@@ -15,7 +18,8 @@ import (
 )
 
 func TestCompressG(t *testing.T) {
-	ParameterSetup(1)
+	p, _ := NewScheme(1)
+	q, m, n, k := p.q, p.m, p.n, p.k
 	G := matrix.New(k, m*n, q)
 	E := matrix.New(k, m*n, q)
 	for i := 0; i < G.M; i++ {
@@ -34,25 +38,25 @@ func TestCompressG(t *testing.T) {
 	for i := 0; i < G.M; i++ {
 		for j := 0; j < G.M; j++ {
 			if i == j {
-				G.Get(i, j).Set(1)
-				E.Get(i, j).Set(1)
+				G.SetValue(i, j, 1)
+				E.SetValue(i, j, 1)
 			} else {
-				G.Get(i, j).Set(0)
-				E.Get(i, j).Set(0)
+				G.SetValue(i, j, 0)
+				E.SetValue(i, j, 0)
 			}
 		}
 	}
 
 	for i := 1; i < m; i++ {
-		G.Get(0, i*(n+1)).Set(1)
-		E.Get(0, i*(n+1)).Set(1)
+		G.SetValue(0, i*(n+1), 1)
+		E.SetValue(0, i*(n+1), 1)
 	}
 	for i := 1; i < m-1; i++ {
-		G.Get(1, i*(n+1)+1).Set(1)
-		E.Get(1, i*(n+1)+1).Set(1)
+		G.SetValue(1, i*(n+1)+1, 1)
+		E.SetValue(1, i*(n+1)+1, 1)
 	}
 
-	R := DecompressG(CompressG(G), q, m, n, k)
+	R := DecompressG(CompressG(G, m, n, k), q, m, n, k)
 	if !R.Equals(E) {
 		t.Errorf("Compressed then decompressed is not equal to itself\nE:%v\nR:%v", E, R)
 		return
@@ -60,7 +64,7 @@ func TestCompressG(t *testing.T) {
 }
 
 func TestSF(t *testing.T) {
-	q = 4093
+	q := 4093
 	for k := 2; k <= 30; k++ {
 		m := k
 		n := k
@@ -108,7 +112,7 @@ func TestSF(t *testing.T) {
 }
 
 func TestSF_on_submatrix(t *testing.T) {
-	q = 4093
+	q := 4093
 	for k := 2; k <= 30; k++ {
 		m := k
 		n := k
@@ -553,7 +557,7 @@ func TestSolve(t *testing.T) {
 		t.Fatalf("A: %v\nB_inv: %v", A, B_inv)
 	}
 
-	q = 4093
+	q := 4093
 	n = 10
 	m = 10
 	k = 10
@@ -905,11 +909,11 @@ func TestSolve(t *testing.T) {
 
 func TestInverse(test *testing.T) {
 	for _, p := range parameterSets {
-		ParameterSetup(p)
+		scheme, _ := NewScheme(p)
 		seed := []byte("SEED_SEED_SEED")
-		A := ExpandInvMat(seed, q, n)
+		A := ExpandInvMat(seed, scheme.q, scheme.n)
 		A_inv := Inverse(A)
-		I := matrix.Identity(30, q)
+		I := matrix.Identity(30, scheme.q)
 		if !A.Mul(A_inv).Equals(I) {
 			test.Errorf("%v failed\n", p)
 		}
@@ -934,9 +938,10 @@ func TestSeedTree(test *testing.T) {
 func TestSeedTreeToPath(test *testing.T) {
 	seed := []byte("seedseedseedseed")
 	salt := []byte("saltsaltsaltsaltsaltsaltsaltsalt")
-	h := ParseHash(s, t, w, []byte("hhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhh"))
+	p, _ := NewScheme(parameterSets[0])
+	h := ParseHash(p.s, p.t, p.w, []byte("hhhhhhhhhhhhhhhhhhhhhhhhhhhhhhhh"))
 	test.Logf("h: %v\n", h)
-	path := SeedTreeToPath(w, t, h, seed, salt)
+	path := SeedTreeToPath(p.w, p.t, h, seed, salt)
 	test.Logf("len(path): %v\n", len(path))
 	test.Errorf("path: %v\n", path)
 }
@@ -952,14 +957,81 @@ func TestPathToSeedTree(test *testing.T) {
 	test.Errorf("seeds: %v\n", seeds)
 }
 
+// TestSeedTreeNodes checks the flat seedTree.Tree against the linked SeedTreeNode implementation
+func TestSeedTreeNodes(test *testing.T) {
+	salt := []byte("saltsaltsaltsaltsaltsaltsaltsalt")
+	for _, t := range []int{1, 2, 3, 5, 112, 160, 192, 608, 1152} {
+		seed := make([]byte, 16)
+		rand.Read(seed)
+		w := min(t, 14)
+		h := make([]byte, t)
+		for _, i := range rand.Perm(t)[:w] {
+			h[i] = byte(1 + rand.Intn(3))
+		}
+		height := int(math.Ceil(math.Log2(float64(t))))
+		l_path := (int(math.Pow(2, math.Ceil(math.Log2(float64(w))))) + w*(height-int(math.Ceil(math.Log2(float64(w))))-1)) * len(seed)
+		l_path = max(l_path, t*len(seed))
+
+		root := seedTree.New(seed, salt, 0, 0, nil, nil, nil)
+		leafs := make([]*seedTree.SeedTreeNode, t)
+		root.CreateSeedTree(height, &leafs)
+		for i := 0; i < t; i++ {
+			if h[i] != 0 {
+				leafs[i].RemoveSeedLabel()
+			}
+		}
+		E_path := make([]byte, l_path)
+		idx := 0
+		root.SeedTreeToPath(&E_path, &idx)
+
+		tree := seedTree.NewTree(t, len(seed))
+		tree.Generate(seed, salt)
+		for i := 0; i < t; i++ {
+			if !bytes.Equal(tree.Leaf(i), leafs[i].Seed()) {
+				test.Fatalf("t = %v: leaf %v differs\n", t, i)
+			}
+		}
+		path := make([]byte, l_path)
+		tree.ToPath(h, path)
+		if !bytes.Equal(path, E_path) {
+			test.Fatalf("t = %v: path differs\n", t)
+		}
+
+		tree = seedTree.NewTree(t, len(seed))
+		err := tree.FromPath(h, path, salt)
+		if err != nil {
+			test.Fatal(err)
+		}
+		for i := 0; i < t; i++ {
+			E := leafs[i].Seed()
+			if h[i] != 0 {
+				E = make([]byte, len(seed))
+			}
+			if !bytes.Equal(tree.Leaf(i), E) {
+				test.Fatalf("t = %v: reconstructed leaf %v differs\n", t, i)
+			}
+		}
+		if idx > 0 && tree.FromPath(h, path[:idx-1], salt) == nil {
+			test.Errorf("t = %v: short path accepted\n", t)
+		}
+		if idx < l_path {
+			path[l_path-1] = 1
+			if tree.FromPath(h, path, salt) == nil {
+				test.Errorf("t = %v: path with non-zero padding accepted\n", t)
+			}
+		}
+	}
+}
+
 func TestBase(test *testing.T) {
-	ParameterSetup(parameterSets[5])
-	delta := Randombytes(l_sec_seed)
-	sigma_G_0 := make([]byte, l_pub_seed)
-	sigma_A := make([]byte, l_pub_seed)
-	sigma_B := make([]byte, l_pub_seed)
-	sigma_Ap := make([]byte, l_pub_seed)
-	sigma_Bp := make([]byte, l_pub_seed)
+	p, _ := NewScheme(parameterSets[5])
+	q, m, n, k := p.q, p.m, p.n, p.k
+	delta, _ := Randombytes(nil, p.l_sec_seed)
+	sigma_G_0 := make([]byte, p.l_pub_seed)
+	sigma_A := make([]byte, p.l_pub_seed)
+	sigma_B := make([]byte, p.l_pub_seed)
+	sigma_Ap := make([]byte, p.l_pub_seed)
+	sigma_Bp := make([]byte, p.l_pub_seed)
 	xof := sha3.NewShake256()
 	xof.Write(delta)
 	xof.Read(sigma_G_0)
@@ -1399,3 +1471,26 @@ func BenchmarkSolve30(b *testing.B) {
 		b.Fatalf("Incorrect\n")
 	}
 }
+
+func benchmarkPi(b *testing.B, set int) {
+	p, _ := NewScheme(set)
+	G_0 := ExpandSystMat([]byte("SEED_SEED_SEED"), p.q, p.k, p.m, p.n)
+	A := ExpandInvMat([]byte("SEED_A"), p.q, p.m)
+	B := ExpandInvMat([]byte("SEED_B"), p.q, p.n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Pi(A, G_0, B)
+	}
+}
+
+func BenchmarkPi9923(b *testing.B) {
+	benchmarkPi(b, 9923)
+}
+
+func BenchmarkPi41711(b *testing.B) {
+	benchmarkPi(b, 41711)
+}
+
+func BenchmarkPi134180(b *testing.B) {
+	benchmarkPi(b, 134180)
+}
@@ -0,0 +1,139 @@
+package meds
+
+import (
+	"meds/matrix"
+	"meds/seedTree"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// workspace is the scratch memory of one round of Sign or Verify.
+// Workspaces are pooled per parameter set, so once the pool is warm a round
+// does not allocate.
+type workspace struct {
+	shake sha3.ShakeHash
+	// buf holds the bytes of one rejection sampling attempt
+	buf                          []byte
+	x, seed, sigma_prime         []byte
+	sigma_A_tilde, sigma_B_tilde []byte
+	A_tilde, B_tilde             *matrix.Matrix
+	A_scratch, B_scratch         *matrix.Matrix
+	P, AP                        *matrix.Matrix
+	G_tilde                      *matrix.Matrix
+	// G_sub holds the last mn-k columns of G_tilde, which are hashed
+	G_sub *matrix.Matrix
+}
+
+// rounds holds the per-call state of Sign and Verify that covers all t rounds
+type rounds struct {
+	// G is the compressed G_sub of every round in round order, i.e. the input of the challenge hash
+	G []byte
+	// sigma is sigma_A_tilde || sigma_B_tilde of every round. It is only used by Sign.
+	sigma []byte
+	// tree is the seed tree of the t rounds
+	tree *seedTree.Tree
+	// delta and rho are the signing randomness and the root seed. They are only used by Sign.
+	delta, rho []byte
+	// d is the digest recomputed by Verify
+	d []byte
+	// order and response are the round order of Verify and the index of the response of each round
+	order, response []int
+}
+
+// pools holds the workspaces of a Scheme. It is shared by the copies
+// returned by WithWorkers.
+type pools struct {
+	workspaces sync.Pool
+	rounds     sync.Pool
+}
+
+func newPools(p *Scheme) *pools {
+	pl := &pools{}
+	pl.workspaces.New = func() any {
+		return &workspace{
+			shake:         sha3.NewShake256(),
+			buf:           make([]byte, Bytelen(p.q)),
+			x:             make([]byte, 4),
+			seed:          make([]byte, p.l_tree_seed),
+			sigma_prime:   make([]byte, 0, p.l_salt+p.l_tree_seed+4),
+			sigma_A_tilde: make([]byte, p.l_pub_seed),
+			sigma_B_tilde: make([]byte, p.l_pub_seed),
+			A_tilde:       matrix.New(p.m, p.m, p.q),
+			B_tilde:       matrix.New(p.n, p.n, p.q),
+			A_scratch:     matrix.New(p.m, p.m, p.q),
+			B_scratch:     matrix.New(p.n, p.n, p.q),
+			P:             matrix.New(p.m, p.n, p.q),
+			AP:            matrix.New(p.m, p.n, p.q),
+			G_tilde:       matrix.New(p.k, p.m*p.n, p.q),
+			G_sub:         matrix.New(p.k, p.m*p.n-p.k, p.q),
+		}
+	}
+	pl.rounds.New = func() any {
+		return &rounds{
+			G:        make([]byte, p.t*p.commitmentSize()),
+			sigma:    make([]byte, p.t*2*p.l_pub_seed),
+			tree:     seedTree.NewTree(p.t, p.l_tree_seed),
+			delta:    make([]byte, p.l_sec_seed),
+			rho:      make([]byte, p.l_tree_seed),
+			d:        make([]byte, p.l_digest),
+			order:    make([]int, 0, p.t),
+			response: make([]int, p.t),
+		}
+	}
+	return pl
+}
+
+// commitmentSize returns the length in bytes of the compressed G_sub of one round
+func (p *Scheme) commitmentSize() int {
+	return matrix.CompressedSize(p.k, p.m*p.n-p.k, p.q)
+}
+
+func (p *Scheme) getWorkspace() *workspace {
+	return p.pools.workspaces.Get().(*workspace)
+}
+
+// putWorkspace wipes the secret scratch memory of ws and returns it to the pool
+func (p *Scheme) putWorkspace(ws *workspace) {
+	ws.clear()
+	p.pools.workspaces.Put(ws)
+}
+
+func (p *Scheme) getRounds() *rounds {
+	return p.pools.rounds.Get().(*rounds)
+}
+
+// putRounds wipes the secret seeds of r and returns it to the pool
+func (p *Scheme) putRounds(r *rounds) {
+	r.clear()
+	p.pools.rounds.Put(r)
+}
+
+// clear overwrites the seeds, the XOF state and the matrices derived from
+// sigma_A_tilde and sigma_B_tilde with zeroes. G_tilde and G_sub are left,
+// since they only hold the commitment, which is hashed into the signature.
+func (ws *workspace) clear() {
+	ws.shake.Reset()
+	clear(ws.buf)
+	clear(ws.seed)
+	clear(ws.sigma_prime[:cap(ws.sigma_prime)])
+	clear(ws.sigma_A_tilde)
+	clear(ws.sigma_B_tilde)
+	for _, M := range []*matrix.Matrix{ws.A_tilde, ws.B_tilde, ws.A_scratch, ws.B_scratch, ws.P, ws.AP} {
+		clear(M.Data())
+	}
+}
+
+// clear overwrites the signing randomness and every seed with zeroes
+func (r *rounds) clear() {
+	clear(r.sigma)
+	clear(r.delta)
+	clear(r.rho)
+	r.tree.Clear()
+}
+
+// compressG_sub compresses the last mn-k columns of ws.G_tilde into out
+func (ws *workspace) compressG_sub(out []byte) {
+	ws.G_tilde.SubmatrixInto(ws.G_sub, 0, ws.G_tilde.N-ws.G_sub.N)
+	ws.G_sub.AppendCompress(out[:0])
+}
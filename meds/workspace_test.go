@@ -0,0 +1,87 @@
+package meds
+
+import (
+	"bytes"
+	"meds/internal/racetest"
+	"meds/matrix"
+	"testing"
+)
+
+// TestAllocs guards the pooled workspaces and seed tree: once the pools are
+// warm, the allocations of Sign and Verify do not depend on t. What is left
+// is parsing the keys, the signature itself and starting the workers.
+func TestAllocs(test *testing.T) {
+	if racetest.Enabled {
+		test.Skip("sync.Pool drops items under the race detector")
+	}
+	p, err := NewScheme(1)
+	if err != nil {
+		test.Fatal(err)
+	}
+	// Every worker goroutine allocates, so their number is fixed
+	p = p.WithWorkers(2)
+	pk, sk, err := p.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	sig, err := p.SignDetached(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	const limit = 64
+	allocs := testing.AllocsPerRun(3, func() {
+		p.SignDetached(nil, sk, msg)
+	})
+	if allocs > limit {
+		test.Errorf("SignDetached: %v allocations, expected at most %v\n", allocs, limit)
+	}
+	allocs = testing.AllocsPerRun(3, func() {
+		err = p.VerifyDetached(pk, msg, sig)
+	})
+	if err != nil {
+		test.Fatal(err)
+	}
+	if allocs > limit {
+		test.Errorf("VerifyDetached: %v allocations, expected at most %v\n", allocs, limit)
+	}
+}
+
+// TestPoolsCleared checks that Sign leaves no secret seeds or matrices in the pooled buffers
+func TestPoolsCleared(test *testing.T) {
+	p, err := NewScheme(1)
+	if err != nil {
+		test.Fatal(err)
+	}
+	p = p.WithWorkers(1)
+	_, sk, err := p.KeyGen(nil)
+	if err != nil {
+		test.Fatal(err)
+	}
+	_, err = p.SignDetached(nil, sk, msg)
+	if err != nil {
+		test.Fatal(err)
+	}
+	isZero := func(b []byte) bool {
+		return bytes.Count(b, []byte{0}) == len(b)
+	}
+
+	r := p.getRounds()
+	if !isZero(r.sigma) || !isZero(r.delta) || !isZero(r.rho) {
+		test.Errorf("Pooled rounds hold signing seeds\n")
+	}
+	for i := 0; i < p.t; i++ {
+		if !isZero(r.tree.Leaf(i)) {
+			test.Fatalf("Pooled seed tree holds the seed of round %v\n", i)
+		}
+	}
+	ws := p.getWorkspace()
+	if !isZero(ws.buf) || !isZero(ws.seed) || !isZero(ws.sigma_prime[:cap(ws.sigma_prime)]) ||
+		!isZero(ws.sigma_A_tilde) || !isZero(ws.sigma_B_tilde) {
+		test.Errorf("Pooled workspace holds seeds\n")
+	}
+	for _, M := range []*matrix.Matrix{ws.A_tilde, ws.B_tilde, ws.A_scratch, ws.B_scratch, ws.P, ws.AP} {
+		if !M.Equals(matrix.New(M.M, M.N, M.Q)) {
+			test.Errorf("Pooled workspace holds a secret matrix\n")
+		}
+	}
+}
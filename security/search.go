@@ -0,0 +1,115 @@
+package security
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"meds/meds"
+	"slices"
+	"text/tabwriter"
+)
+
+// Candidate is a choice of s, t and w that reaches the target soundness
+type Candidate struct {
+	Parameters meds.Parameters `json:"parameters"`
+	// Soundness is -log2 of the probability of guessing the challenge
+	Soundness     float64 `json:"soundness"`
+	PublicKeySize int     `json:"pk_size"`
+	SignatureSize int     `json:"sig_size"`
+	// SigningCost is the estimated number of F_q multiplications of Sign
+	SigningCost float64 `json:"signing_cost"`
+}
+
+// Search enumerates s in [2, max_s] and t in [1, max_t] for the q, m, n, k
+// and seed lengths of base. For every s and t it keeps the smallest w whose
+// soundness reaches lambda bits, since more rounds with a non-zero challenge
+// only grow the signature.
+// Returns: The candidates ordered by s and t, or an error if base and the bounds are not valid
+func Search(base meds.Parameters, lambda float64, max_s, max_t int) ([]Candidate, error) {
+	var candidates []Candidate
+	for s := 2; s <= max_s; s++ {
+		for t := 1; t <= max_t; t++ {
+			w := 1
+			for w <= t && Soundness(s, t, w) < lambda {
+				w++
+			}
+			if w > t {
+				continue
+			}
+			params := base
+			params.S, params.T, params.W = s, t, w
+			scheme, err := meds.NewSchemeFromParameters(params)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, Candidate{
+				Parameters:    params,
+				Soundness:     Soundness(s, t, w),
+				PublicKeySize: scheme.PublicKeySize(),
+				SignatureSize: scheme.SignatureSize(),
+				SigningCost:   signingCost(params),
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// signingCost estimates the F_q multiplications of Sign. Every one of the t
+// commitments computes A G_0 B, with km(mn + n^2) multiplications for the
+// k rows, and its systematic form, with about k^2 mn. The w responses
+// multiply m x m and n x n matrices.
+func signingCost(params meds.Parameters) float64 {
+	m, n, k := float64(params.M), float64(params.N), float64(params.K)
+	commitment := k*m*(m*n+n*n) + k*k*m*n
+	response := m*m*m + n*n*n
+	return float64(params.T)*commitment + float64(params.W)*response
+}
+
+// ParetoFront returns the candidates that no other candidate beats in public
+// key size, signature size and signing cost at once. Of equal candidates
+// only the first is kept.
+// Returns: The front ordered by signature size
+func ParetoFront(candidates []Candidate) []Candidate {
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, func(a, b Candidate) int {
+		return cmp.Or(
+			cmp.Compare(a.SignatureSize, b.SignatureSize),
+			cmp.Compare(a.PublicKeySize, b.PublicKeySize),
+			cmp.Compare(a.SigningCost, b.SigningCost),
+		)
+	})
+	// A candidate can only be dominated by one before it, and if so also by
+	// one on the front
+	var front []Candidate
+	for _, c := range sorted {
+		dominated := slices.ContainsFunc(front, func(f Candidate) bool {
+			return f.PublicKeySize <= c.PublicKeySize && f.SigningCost <= c.SigningCost
+		})
+		if !dominated {
+			front = append(front, c)
+		}
+	}
+	return front
+}
+
+// WriteCandidates writes the candidates as a table with one row per candidate
+// Returns: An error if writing to w fails
+func WriteCandidates(w io.Writer, candidates []Candidate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "s\tt\tw\tsoundness\tpk size\tsig size\tsigning cost\t\n")
+	for _, c := range candidates {
+		p := c.Parameters
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%.1f\t%v\t%v\t%.3g\t\n",
+			p.S, p.T, p.W, c.Soundness, c.PublicKeySize, c.SignatureSize, c.SigningCost)
+	}
+	return tw.Flush()
+}
+
+// WriteCandidatesJSON writes the candidates as a JSON array
+// Returns: An error if writing to w fails
+func WriteCandidatesJSON(w io.Writer, candidates []Candidate) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(candidates)
+}
@@ -0,0 +1,100 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"meds/meds"
+	"testing"
+)
+
+func TestSearch(test *testing.T) {
+	builtin, _ := meds.NewScheme(9923)
+	candidates, err := Search(builtin.Parameters(), 128, 4, 1152)
+	if err != nil {
+		test.Fatal(err)
+	}
+	found := false
+	for _, c := range candidates {
+		p := c.Parameters
+		if c.Soundness < 128 || (p.W > 1 && Soundness(p.S, p.T, p.W-1) >= 128) {
+			test.Errorf("w = %v is not the smallest for s = %v, t = %v\n", p.W, p.S, p.T)
+		}
+		if p.S == 4 && p.T == 1152 {
+			found = true
+			if p != builtin.Parameters() || c.PublicKeySize != builtin.PublicKeySize() || c.SignatureSize != builtin.SignatureSize() {
+				test.Errorf("MEDS-9923 candidate %+v\n", c)
+			}
+		}
+	}
+	if !found {
+		test.Errorf("MEDS-9923 is not a candidate\n")
+	}
+
+	invalid := builtin.Parameters()
+	invalid.Q = 4095
+	if _, err := Search(invalid, 128, 4, 1152); err == nil {
+		test.Errorf("Composite q accepted\n")
+	}
+}
+
+// dominates reports whether a is at least as good as b in every objective and better in one
+func dominates(a, b Candidate) bool {
+	return a.PublicKeySize <= b.PublicKeySize && a.SignatureSize <= b.SignatureSize && a.SigningCost <= b.SigningCost &&
+		(a.PublicKeySize < b.PublicKeySize || a.SignatureSize < b.SignatureSize || a.SigningCost < b.SigningCost)
+}
+
+func TestParetoFront(test *testing.T) {
+	builtin, _ := meds.NewScheme(9923)
+	candidates, _ := Search(builtin.Parameters(), 128, 8, 512)
+	front := ParetoFront(candidates)
+	if len(front) == 0 || len(front) == len(candidates) {
+		test.Fatalf("Front has %v of %v candidates\n", len(front), len(candidates))
+	}
+	for i, f := range front {
+		if i > 0 && front[i-1].SignatureSize > f.SignatureSize {
+			test.Errorf("Front is not ordered by signature size\n")
+		}
+		for _, c := range candidates {
+			if dominates(c, f) {
+				test.Errorf("%+v on the front is dominated by %+v\n", f, c)
+			}
+		}
+	}
+	for _, c := range candidates {
+		covered := false
+		for _, f := range front {
+			covered = covered || f == c || dominates(f, c)
+		}
+		if !covered {
+			test.Errorf("%+v is missing from the front\n", c)
+		}
+	}
+	// Duplicates are kept once
+	if twice := ParetoFront(append(front, front...)); len(twice) != len(front) {
+		test.Errorf("Front of duplicates has %v of %v candidates\n", len(twice), len(front))
+	}
+}
+
+func TestWriteCandidates(test *testing.T) {
+	builtin, _ := meds.NewScheme(9923)
+	candidates, _ := Search(builtin.Parameters(), 128, 4, 256)
+	front := ParetoFront(candidates)
+	var b bytes.Buffer
+	err := WriteCandidatesJSON(&b, front)
+	if err != nil {
+		test.Fatal(err)
+	}
+	var decoded []Candidate
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		test.Fatal(err)
+	}
+	if len(decoded) != len(front) || decoded[0] != front[0] {
+		test.Errorf("JSON round trip: %+v\n", decoded)
+	}
+	b.Reset()
+	err = WriteCandidates(&b, front)
+	if err != nil || bytes.Count(b.Bytes(), []byte("\n")) != len(front)+1 {
+		test.Errorf("Unexpected table (%v):\n%v", err, b.String())
+	}
+}
@@ -0,0 +1,271 @@
+// Package security estimates the bit security of MEDS parameter sets from
+// the soundness of the Fiat-Shamir challenges and the cost of the known
+// attacks on matrix code equivalence. The estimates are coarse models
+// meant to compare parameter sets, not security proofs.
+package security
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"meds/meds"
+	"text/tabwriter"
+)
+
+// omega is the exponent of linear algebra. 2 leaves out the constant of
+// Gaussian elimination, which favours the attacker.
+const omega = 2.0
+
+// maxDegree bounds the search for the degree of regularity of the algebraic attack
+const maxDegree = 100
+
+// Cost is the security against one attack in bits: log2 of the number of
+// F_q operations, or -log2 of the success probability of one forgery attempt
+type Cost struct {
+	Classical float64
+	Quantum   float64
+}
+
+// Report is the estimated security of a parameter set
+type Report struct {
+	// Set is the identifier of the parameter set, or 0 if the parameters are not valid
+	Set        int
+	Parameters meds.Parameters
+	// Soundness is the security against guessing the Fiat-Shamir challenge
+	Soundness Cost
+	// Leon is the cost of finding all codewords of rank LeonRank in both
+	// codes and matching them
+	Leon     Cost
+	LeonRank int
+	// Birthday is the cost of the Beullens-style collision search between
+	// lists of codewords of rank BirthdayRank
+	Birthday     Cost
+	BirthdayRank int
+	// Algebraic is the cost of a Groebner basis of the bilinear modelling,
+	// which reaches the degree of regularity DegreeOfRegularity
+	Algebraic          Cost
+	DegreeOfRegularity int
+}
+
+// Security returns the smallest cost of all attacks
+func (r Report) Security() Cost {
+	costs := []Cost{r.Soundness, r.Leon, r.Birthday, r.Algebraic}
+	min := costs[0]
+	for _, c := range costs[1:] {
+		min.Classical = math.Min(min.Classical, c.Classical)
+		min.Quantum = math.Min(min.Quantum, c.Quantum)
+	}
+	return min
+}
+
+// Estimate estimates the security of the parameter set params
+// Returns: Report
+func Estimate(params meds.Parameters) Report {
+	r := Report{Parameters: params}
+	scheme, err := meds.NewSchemeFromParameters(params)
+	if err == nil {
+		r.Set = scheme.ParameterSet()
+	}
+	soundness := Soundness(params.S, params.T, params.W)
+	// Grover searches for a salted message hashing to a challenge the forger can answer
+	r.Soundness = Cost{soundness, soundness / 2}
+	r.Leon, r.LeonRank = leon(params)
+	r.Birthday, r.BirthdayRank = birthday(params)
+	r.Algebraic, r.DegreeOfRegularity = algebraic(params)
+	return r
+}
+
+// EstimateBuiltin estimates the security of all parameter sets returned by meds.ParameterSets
+// Returns: One report per parameter set, in order
+func EstimateBuiltin() []Report {
+	var reports []Report
+	for _, set := range meds.ParameterSets() {
+		scheme, _ := meds.NewScheme(set)
+		r := Estimate(scheme.Parameters())
+		r.Set = set
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// Soundness returns -log2 of the probability of guessing the challenge of a
+// signature with t rounds, of which w have one of the s-1 non-zero challenges.
+// There are binom(t, w) (s-1)^w such challenges.
+func Soundness(s, t, w int) float64 {
+	return log2Binomial(t, w) + float64(w)*math.Log2(float64(s-1))
+}
+
+// log2Binomial returns log2 binom(n, k)
+func log2Binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return math.Inf(-1)
+	}
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return (a - b - c) / math.Ln2
+}
+
+// log2Add returns log2(2^a + 2^b)
+func log2Add(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log2(1+math.Exp2(b-a))
+}
+
+// rankCodewords returns log_q of the expected number of codewords of rank r
+// in a random k-dimensional code in F_q^{m x n}, which has q^{k-mn} of the
+// about q^{r(m+n-r)} matrices of rank r
+func rankCodewords(params meds.Parameters, r int) float64 {
+	return float64(params.K - params.M*params.N + r*(params.M+params.N-r))
+}
+
+// minRank returns the smallest rank r at which a random code is expected to have a codeword
+func minRank(params meds.Parameters) int {
+	r := 1
+	for r < min(params.M, params.N) && rankCodewords(params, r) < 0 {
+		r++
+	}
+	return r
+}
+
+// kernelGuesses returns the number of kernel vectors to guess to find a
+// codeword of rank r with the kernel method of Goubin and Courtois. Every
+// guessed vector gives max(m, n) linear equations on the k coefficients.
+func kernelGuesses(params meds.Parameters) int {
+	return max(1, (params.K-1+max(params.M, params.N)-1)/max(params.M, params.N))
+}
+
+// leon estimates the Leon-like attack: it enumerates all kernel guesses to
+// find the codewords of rank r in both codes, then tries to map two of them
+// onto two codewords of the other code. Every candidate pair of pairs gives
+// 2mn linear equations on A and B^-1.
+// Returns: (Cost, the rank r of the cheapest attack)
+func leon(params meds.Parameters) (Cost, int) {
+	return minOverRank(params, func(r int, log_q float64) Cost {
+		N := math.Max(rankCodewords(params, r)*log_q, 1)
+		search := float64(r*kernelGuesses(params)) * log_q
+		find := 1 + omega*math.Log2(float64(params.K))
+		check := omega * math.Log2(float64(params.M*params.M+params.N*params.N))
+		return Cost{
+			log2Add(find+math.Max(search, N), 2*N+check),
+			// Grover speeds up the search for kernels and the matching
+			log2Add(find+math.Max(search, N)/2, N+check),
+		}
+	})
+}
+
+// birthday estimates the Beullens-style attack: it builds lists of
+// L = sqrt(2N) codewords of rank r in both codes, where N is the number of
+// such codewords, so that two pairs related by the equivalence are expected.
+// Every candidate pair of pairs is checked as in leon.
+// Returns: (Cost, the rank r of the cheapest attack)
+func birthday(params meds.Parameters) (Cost, int) {
+	return minOverRank(params, func(r int, log_q float64) Cost {
+		N := rankCodewords(params, r) * log_q
+		L := (1 + N) / 2
+		// Finding one codeword takes q^{r g} / N kernel guesses, but at least one
+		search := math.Max(float64(r*kernelGuesses(params))*log_q-N, 0)
+		find := 1 + L + omega*math.Log2(float64(params.K))
+		check := omega * math.Log2(float64(params.M*params.M+params.N*params.N))
+		return Cost{
+			log2Add(find+search, 4*L+check),
+			log2Add(find+search/2, 2*L+check),
+		}
+	})
+}
+
+// minOverRank returns the cheapest cost(r, log2 q) over the ranks r at
+// which a code is expected to have codewords
+func minOverRank(params meds.Parameters, cost func(r int, log_q float64) Cost) (Cost, int) {
+	log_q := math.Log2(float64(params.Q))
+	best, best_r := Cost{math.Inf(1), math.Inf(1)}, 0
+	for r := minRank(params); r <= min(params.M, params.N); r++ {
+		c := cost(r, log_q)
+		if c.Classical < best.Classical {
+			best, best_r = c, r
+		}
+		best.Quantum = math.Min(best.Quantum, c.Quantum)
+	}
+	return best, best_r
+}
+
+// algebraic estimates a Groebner basis computation on the equations
+// A G_0^(i) = (sum_j T_ij G_1^(j)) B^-1 for i < k. Eliminating the m^2
+// entries of A, which appear linearly, leaves kmn - m^2 bilinear equations
+// in the k^2 entries of T and the n^2 entries of B^-1. The system is assumed
+// to be generic, so its degree of regularity is the first total degree with a
+// non-positive coefficient in the Hilbert series
+//
+//	(1 - t_1 t_2)^E / ((1 - t_1)^{k^2} (1 - t_2)^{n^2})
+//
+// and the cost is that of linear algebra on the Macaulay matrix in that degree.
+// No quantum speedup is known.
+// Returns: (Cost, the degree of regularity, or 0 if it exceeds maxDegree)
+func algebraic(params meds.Parameters) (Cost, int) {
+	nx, ny := params.K*params.K, params.N*params.N
+	E := params.K*params.M*params.N - params.M*params.M
+	d := degreeOfRegularity(nx, ny, E)
+	if d == 0 {
+		return Cost{math.Inf(1), math.Inf(1)}, 0
+	}
+	c := omega * log2Binomial(nx+ny+d, d)
+	return Cost{c, c}, d
+}
+
+// degreeOfRegularity returns the first total degree d at which a coefficient
+// of the Hilbert series of E generic bilinear equations in nx and ny variables
+// is not positive
+// Returns: d, or 0 if there is none up to maxDegree
+func degreeOfRegularity(nx, ny, E int) int {
+	if E <= 0 {
+		return 0
+	}
+	var coefficient, term, b big.Int
+	for d := 1; d <= maxDegree; d++ {
+		for a := 0; a <= d; a++ {
+			// The coefficient of t_1^a t_2^(d-a) is
+			// sum_i (-1)^i binom(E, i) binom(nx-1+a-i, a-i) binom(ny-1+d-a-i, d-a-i)
+			coefficient.SetInt64(0)
+			for i := 0; i <= min(a, d-a, E); i++ {
+				term.Binomial(int64(E), int64(i))
+				term.Mul(&term, b.Binomial(int64(nx-1+a-i), int64(a-i)))
+				term.Mul(&term, b.Binomial(int64(ny-1+d-a-i), int64(d-a-i)))
+				if i%2 == 1 {
+					term.Neg(&term)
+				}
+				coefficient.Add(&coefficient, &term)
+			}
+			if coefficient.Sign() <= 0 {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// WriteTable writes the reports as a table with one row per parameter set
+// Returns: An error if writing to w fails
+func WriteTable(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "set\tq\tm\tn\tk\ts\tt\tw\tsoundness\tLeon\tbirthday\talgebraic\tclassical\tquantum\t\n")
+	for _, r := range reports {
+		p := r.Parameters
+		security := r.Security()
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n",
+			r.Set, p.Q, p.M, p.N, p.K, p.S, p.T, p.W,
+			bits(r.Soundness.Classical), bits(r.Leon.Classical), bits(r.Birthday.Classical),
+			bits(r.Algebraic.Classical), bits(security.Classical), bits(security.Quantum))
+	}
+	return tw.Flush()
+}
+
+// bits formats a cost in bits
+func bits(c float64) string {
+	if math.IsInf(c, 1) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", c)
+}
@@ -0,0 +1,102 @@
+package security
+
+import (
+	"bytes"
+	"math"
+	"meds/meds"
+	"strings"
+	"testing"
+)
+
+func TestSoundness(test *testing.T) {
+	cases := []struct {
+		s, t, w int
+		bits    float64
+	}{
+		// binom(4, 2) = 6 challenges
+		{2, 4, 2, math.Log2(6)},
+		// binom(4, 2) 3^2 = 54 challenges
+		{4, 4, 2, math.Log2(54)},
+		// A single round with one of 255 non-zero challenges
+		{256, 1, 1, math.Log2(255)},
+	}
+	for _, c := range cases {
+		if bits := Soundness(c.s, c.t, c.w); math.Abs(bits-c.bits) > 1e-9 {
+			test.Errorf("Soundness(%v, %v, %v) = %v, expected %v\n", c.s, c.t, c.w, bits, c.bits)
+		}
+	}
+}
+
+func TestDegreeOfRegularity(test *testing.T) {
+	// Fewer equations than variables leave the system positive dimensional
+	if d := degreeOfRegularity(10, 10, 5); d != 0 {
+		test.Errorf("Underdetermined system has degree of regularity %v\n", d)
+	}
+	// With as many equations as monomials t_1 t_2, the series vanishes in degree 2
+	if d := degreeOfRegularity(3, 3, 9); d != 2 {
+		test.Errorf("degreeOfRegularity(3, 3, 9) = %v, expected 2\n", d)
+	}
+	// More equations can only lower the degree
+	last := maxDegree
+	for E := 600; E <= 3000; E += 200 {
+		d := degreeOfRegularity(196, 196, E)
+		if d == 0 || d > last {
+			test.Errorf("degreeOfRegularity(196, 196, %v) = %v after %v\n", E, d, last)
+		}
+		last = d
+	}
+}
+
+func TestBuiltin(test *testing.T) {
+	levels := map[int]float64{9923: 128, 13220: 128, 41711: 192, 69497: 192, 134180: 256, 167717: 256}
+	reports := EstimateBuiltin()
+	if len(reports) != len(meds.ParameterSets()) {
+		test.Fatalf("%v reports for %v parameter sets\n", len(reports), len(meds.ParameterSets()))
+	}
+	for i, set := range meds.ParameterSets() {
+		r := reports[i]
+		security := r.Security()
+		if r.Set != set {
+			test.Errorf("Report %v is for set %v, expected %v\n", i, r.Set, set)
+		}
+		if set == 1 {
+			// The toy parameter set is only meant for testing
+			if security.Classical > 64 {
+				test.Errorf("MEDS-1: %v bits\n", security.Classical)
+			}
+			continue
+		}
+		// The challenges are chosen for the security level of the set
+		if r.Soundness.Classical < levels[set] {
+			test.Errorf("MEDS-%v: soundness %v below %v\n", set, r.Soundness.Classical, levels[set])
+		}
+		for name, c := range map[string]Cost{"Leon": r.Leon, "birthday": r.Birthday, "algebraic": r.Algebraic} {
+			if c.Quantum > c.Classical || c.Classical < levels[set]-16 {
+				test.Errorf("MEDS-%v: %v cost %+v\n", set, name, c)
+			}
+		}
+		if security.Classical > r.Soundness.Classical || security.Quantum > security.Classical {
+			test.Errorf("MEDS-%v: security %+v\n", set, security)
+		}
+	}
+	// The sets come in pairs with the same code, and larger codes are harder to attack
+	for i := 3; i < len(reports); i += 2 {
+		if reports[i].Birthday.Classical <= reports[i-2].Birthday.Classical ||
+			reports[i].Leon.Classical <= reports[i-2].Leon.Classical ||
+			reports[i].Algebraic.Classical <= reports[i-2].Algebraic.Classical {
+			test.Errorf("Costs do not grow from %+v to %+v\n", reports[i-2], reports[i])
+		}
+	}
+}
+
+func TestWriteTable(test *testing.T) {
+	var b bytes.Buffer
+	err := WriteTable(&b, EstimateBuiltin())
+	if err != nil {
+		test.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 1+len(meds.ParameterSets()) || !strings.Contains(lines[0], "soundness") {
+		test.Errorf("Unexpected table:\n%v", b.String())
+	}
+}
@@ -1,7 +1,6 @@
 package seedTree
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -27,7 +26,31 @@ func (node *SeedTreeNode) Seed() []byte {
 	return node.seed
 }
 
+// hasher is the XOF and address buffer shared by all nodes of one tree walk,
+// so that a node does not allocate its own
+type hasher struct {
+	G       sha3.ShakeHash
+	address [2]byte
+}
+
+func newHasher() *hasher {
+	return &hasher{G: sha3.NewShake256()}
+}
+
+// init resets the XOF and absorbs salt || address(i, j) || seed
+func (h *hasher) init(salt []byte, i, j int, seed []byte) {
+	h.G.Reset()
+	h.G.Write(salt)
+	binary.LittleEndian.PutUint16(h.address[:], uint16(int(math.Pow(2, float64(i)))-1+j))
+	h.G.Write(h.address[:])
+	h.G.Write(seed)
+}
+
 func (node *SeedTreeNode) CreateSeedTree(maxHeight int, leafs *[]*SeedTreeNode) error {
+	return node.createSeedTree(newHasher(), maxHeight, leafs)
+}
+
+func (node *SeedTreeNode) createSeedTree(h *hasher, maxHeight int, leafs *[]*SeedTreeNode) error {
 	if node.j >= len(*leafs) {
 		return nil
 	}
@@ -37,18 +60,18 @@ func (node *SeedTreeNode) CreateSeedTree(maxHeight int, leafs *[]*SeedTreeNode)
 		return nil
 	}
 
-	err := node.createChildren()
+	err := node.createChildren(h)
 	if err != nil {
 		return err
 	}
 
-	err = node.left.CreateSeedTree(maxHeight-1, leafs)
+	err = node.left.createSeedTree(h, maxHeight-1, leafs)
 	if err != nil {
 		return err
 	}
 
 	if (*leafs)[len(*leafs)-1] == nil {
-		err = node.right.CreateSeedTree(maxHeight-1, leafs)
+		err = node.right.createSeedTree(h, maxHeight-1, leafs)
 	} else {
 		node.right = nil
 	}
@@ -56,35 +79,25 @@ func (node *SeedTreeNode) CreateSeedTree(maxHeight int, leafs *[]*SeedTreeNode)
 	return err
 }
 
-// Creates the children for this node
+// Creates the children for this node. Both children and their seeds are
+// allocated together.
 // Precondition: Node does not have any children currently
-func (node *SeedTreeNode) createChildren() error {
-	G := sha3.NewShake256()
-	G.Write(node.salt)
-	address, err := getAddress(node.i, node.j)
-	if err != nil {
-		return err
-	}
-	G.Write(address)
-	G.Write(node.seed)
+func (node *SeedTreeNode) createChildren(h *hasher) error {
+	h.init(node.salt, node.i, node.j, node.seed)
 	seedLength := len(node.seed)
-	node.left = New(make([]byte, seedLength), node.salt, node.i+1, 2*node.j, node, nil, nil)
-	node.right = New(make([]byte, seedLength), node.salt, node.i+1, 2*node.j+1, node, nil, nil)
-	G.Read(node.left.seed)
-	G.Read(node.right.seed)
+	seeds := make([]byte, 2*seedLength)
+	children := &[2]SeedTreeNode{
+		{seeds[:seedLength:seedLength], node.salt, node.i + 1, 2 * node.j, node, nil, nil},
+		{seeds[seedLength:], node.salt, node.i + 1, 2*node.j + 1, node, nil, nil},
+	}
+	node.left = &children[0]
+	node.right = &children[1]
+	h.G.Read(node.left.seed)
+	h.G.Read(node.right.seed)
 
 	return nil
 }
 
-func getAddress(i, j int) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, uint16(int(math.Pow(2, float64(i)))-1+j))
-	if err != nil {
-		return []byte{}, err
-	}
-	return buf.Bytes(), nil
-}
-
 func (node *SeedTreeNode) SeedTreeToPath(path *[]byte, idx *int) {
 	if node.HasLabel() {
 		seed := node.Seed()
@@ -145,28 +158,33 @@ func (node *SeedTreeNode) isLeaf() bool {
 	return node.left == nil && node.right == nil
 }
 
-func (node *SeedTreeNode) pathToSeedTreeComputeSeeds() error {
-	G := sha3.NewShake256()
-	G.Write(node.salt)
-	address, err := getAddress(node.i, node.j)
-	if err != nil {
-		return err
+func (node *SeedTreeNode) pathToSeedTreeComputeSeeds(h *hasher) error {
+	if node.isLeaf() {
+		return nil
 	}
-	G.Write(address)
-	G.Write(node.seed)
+	var err error
+	seedLength := len(node.seed)
+	seeds := make([]byte, 2*seedLength)
+	// The XOF is shared, so both child seeds are read before descending
+	h.init(node.salt, node.i, node.j, node.seed)
+	if node.left != nil {
+		node.left.seed = seeds[:seedLength:seedLength]
+		h.G.Read(node.left.seed)
+	}
+	if node.right != nil {
+		node.right.seed = seeds[seedLength:]
+		h.G.Read(node.right.seed)
+	}
+
 	if node.left != nil {
-		node.left.seed = make([]byte, len(node.seed))
-		G.Read(node.left.seed)
-		err = node.left.pathToSeedTreeComputeSeeds()
+		err = node.left.pathToSeedTreeComputeSeeds(h)
 		if err != nil {
 			return err
 		}
 	}
 
 	if node.right != nil {
-		node.right.seed = make([]byte, len(node.seed))
-		G.Read(node.right.seed)
-		err = node.right.pathToSeedTreeComputeSeeds()
+		err = node.right.pathToSeedTreeComputeSeeds(h)
 	}
 	return err
 
@@ -195,7 +213,7 @@ func (node *SeedTreeNode) PathToSeedTree(path *[]byte, l_tree_seed int, seeds *[
 	if node.HasLabel() {
 		node.seed = (*path)[(*path_idx) : (*path_idx)+l_tree_seed]
 		(*path_idx) += l_tree_seed
-		err = node.pathToSeedTreeComputeSeeds()
+		err = node.pathToSeedTreeComputeSeeds(newHasher())
 		if err != nil {
 			return err
 		}
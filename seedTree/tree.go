@@ -0,0 +1,168 @@
+package seedTree
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Tree is a seed tree with t leaves whose nodes are stored level by level in
+// one flat array. It computes the same seeds and paths as SeedTreeNode, but
+// can be rebuilt for every signature without allocating.
+type Tree struct {
+	t, height, seedLength int
+	// offset[i] is the index of the first node of level i. Level i holds the
+	// offset[i+1]-offset[i] nodes whose subtrees contain at least one of the t leaves.
+	offset []int
+	// seeds holds the seed of node n at seeds[n*seedLength:(n+1)*seedLength]
+	seeds []byte
+	// hidden[n] is set if a challenged leaf is below node n, so its seed is not revealed
+	hidden []bool
+	h      *hasher
+}
+
+// NewTree allocates a seed tree with t leaves and seeds of seedLength bytes
+// Precondition: t > 0
+func NewTree(t, seedLength int) *Tree {
+	height := bits.Len(uint(t - 1))
+	offset := make([]int, height+2)
+	for i := 0; i <= height; i++ {
+		// Level i has ceil(t / 2^(height-i)) nodes
+		offset[i+1] = offset[i] + (t+1<<(height-i)-1)>>(height-i)
+	}
+	nodes := offset[height+1]
+	return &Tree{
+		t:          t,
+		height:     height,
+		seedLength: seedLength,
+		offset:     offset,
+		seeds:      make([]byte, nodes*seedLength),
+		hidden:     make([]bool, nodes),
+		h:          newHasher(),
+	}
+}
+
+// width returns the number of nodes on level i
+func (T *Tree) width(i int) int {
+	return T.offset[i+1] - T.offset[i]
+}
+
+// node returns the seed of node (i, j). The slice shares memory with the tree.
+func (T *Tree) node(i, j int) []byte {
+	n := T.offset[i] + j
+	return T.seeds[n*T.seedLength : (n+1)*T.seedLength : (n+1)*T.seedLength]
+}
+
+// Generate derives every seed of the tree from the root seed and salt
+// Precondition: len(seed) is the seed length of the tree
+func (T *Tree) Generate(seed, salt []byte) {
+	copy(T.node(0, 0), seed)
+	T.expand(0, 0, salt)
+}
+
+// expand derives the seeds of the subtree below node (i, j) from its seed
+func (T *Tree) expand(i, j int, salt []byte) {
+	if i == T.height {
+		return
+	}
+	// Both child seeds are read before descending, since the XOF is shared
+	T.h.init(salt, i, j, T.node(i, j))
+	T.h.G.Read(T.node(i+1, 2*j))
+	right := 2*j+1 < T.width(i+1)
+	if right {
+		T.h.G.Read(T.node(i+1, 2*j+1))
+	}
+	T.expand(i+1, 2*j, salt)
+	if right {
+		T.expand(i+1, 2*j+1, salt)
+	}
+}
+
+// Leaf returns the seed of leaf j. The slice shares memory with the tree.
+func (T *Tree) Leaf(j int) []byte {
+	return T.node(T.height, j)
+}
+
+// hide marks the leaves j with challenge[j] != 0 and all their ancestors as hidden
+func (T *Tree) hide(challenge []byte) {
+	for j := 0; j < T.t; j++ {
+		T.hidden[T.offset[T.height]+j] = challenge[j] != 0
+	}
+	for i := T.height - 1; i >= 0; i-- {
+		for j := 0; j < T.width(i); j++ {
+			hidden := T.hidden[T.offset[i+1]+2*j]
+			if 2*j+1 < T.width(i+1) {
+				hidden = hidden || T.hidden[T.offset[i+1]+2*j+1]
+			}
+			T.hidden[T.offset[i]+j] = hidden
+		}
+	}
+}
+
+// ToPath writes the seeds of the largest subtrees without a challenged leaf
+// into path, left to right, and zeroes the rest of path
+// Precondition: len(challenge) is t and path is long enough for the challenge
+func (T *Tree) ToPath(challenge, path []byte) {
+	T.hide(challenge)
+	clear(path)
+	T.toPath(0, 0, path[:0])
+}
+
+func (T *Tree) toPath(i, j int, path []byte) []byte {
+	if !T.hidden[T.offset[i]+j] {
+		return append(path, T.node(i, j)...)
+	}
+	if i == T.height {
+		return path
+	}
+	path = T.toPath(i+1, 2*j, path)
+	if 2*j+1 < T.width(i+1) {
+		path = T.toPath(i+1, 2*j+1, path)
+	}
+	return path
+}
+
+// FromPath rebuilds the seeds of the leaves j with challenge[j] == 0 from a
+// path written by ToPath. The seeds of the challenged leaves are zeroed.
+// Returns: nil, or an error if path is too short for the challenge or the
+// bytes after the revealed seeds are not zero, so every path has one encoding
+func (T *Tree) FromPath(challenge, path, salt []byte) error {
+	T.hide(challenge)
+	padding, err := T.fromPath(0, 0, path, salt)
+	if err != nil {
+		return err
+	}
+	for _, b := range padding {
+		if b != 0 {
+			return errors.New("seed tree path has non-zero padding")
+		}
+	}
+	return nil
+}
+
+func (T *Tree) fromPath(i, j int, path, salt []byte) ([]byte, error) {
+	if !T.hidden[T.offset[i]+j] {
+		if len(path) < T.seedLength {
+			return nil, errors.New("seed tree path is too short")
+		}
+		copy(T.node(i, j), path)
+		T.expand(i, j, salt)
+		return path[T.seedLength:], nil
+	}
+	if i == T.height {
+		clear(T.node(i, j))
+		return path, nil
+	}
+	path, err := T.fromPath(i+1, 2*j, path, salt)
+	if err != nil {
+		return nil, err
+	}
+	if 2*j+1 < T.width(i+1) {
+		return T.fromPath(i+1, 2*j+1, path, salt)
+	}
+	return path, nil
+}
+
+// Clear overwrites every seed of the tree with zeroes
+func (T *Tree) Clear() {
+	clear(T.seeds)
+}